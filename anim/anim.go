@@ -0,0 +1,177 @@
+// Package anim lets components animate from their normal event loop, by receiving Tick events
+// instead of sleeping between draw functions.
+package anim
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+// Option is a functional option to New.
+type Option func(*options)
+
+type options struct {
+	interval time.Duration
+}
+
+// Rate option sets the number of Tick events per second while animating. The default is 60.
+// Rates which are not positive, or too high to be timed, are ignored.
+func Rate(perSecond float64) Option {
+	return func(o *options) {
+		if !(perSecond > 0) {
+			return
+		}
+		if interval := time.Duration(float64(time.Second) / perSecond); interval > 0 {
+			o.interval = interval
+		}
+	}
+}
+
+// Framer is implemented by Envs that report when they flush a frame to the screen, such as
+// win.Win. Each flush sends its time to the Frames() channel, without blocking.
+type Framer interface {
+	Frames() <-chan time.Time
+}
+
+// Clock injects Tick events into an Env while something is animating. Components call Start
+// when they begin an animation and Stop when they end it. When nothing is animating, the Clock
+// produces no events at all.
+type Clock struct {
+	mu        sync.Mutex
+	animating int
+	wake      chan struct{}
+}
+
+// New creates a Clock for the Env. It returns the Clock along with an Env that produces the
+// events of env interleaved with Tick events and draws to env.
+//
+// The Tick events are spaced by the interval set by the Rate option, on a fixed schedule, so
+// that animations don't drift. If env implements Framer, a Tick is not sent until the frame
+// drawn after the previous Tick got flushed, so that there are no redundant frames. Without
+// such a frame, the Tick gets sent one interval late.
+func New(env gui.Env, opts ...Option) (*Clock, gui.Env) {
+	o := options{
+		interval: time.Second / 60,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var frames <-chan time.Time
+	if f, ok := env.(Framer); ok {
+		frames = f.Frames()
+	}
+
+	c := &Clock{wake: make(chan struct{}, 1)}
+	out, in := gui.MakeEventsChan()
+	go c.run(env, in, frames, o.interval)
+	return c, &clockEnv{out, env.Draw()}
+}
+
+// Start makes the Clock produce Tick events until Stop gets called. Calls to Start and Stop
+// nest: the Clock keeps producing Tick events until every Start got matched by a Stop.
+func (c *Clock) Start() {
+	c.mu.Lock()
+	c.animating++
+	c.mu.Unlock()
+	c.notify()
+}
+
+// Stop undoes one call to Start.
+func (c *Clock) Stop() {
+	c.mu.Lock()
+	if c.animating > 0 {
+		c.animating--
+	}
+	c.mu.Unlock()
+	c.notify()
+}
+
+func (c *Clock) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Clock) isAnimating() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.animating > 0
+}
+
+func (c *Clock) run(env gui.Env, in chan<- gui.Event, frames <-chan time.Time, interval time.Duration) {
+	defer close(in)
+
+	timer := time.NewTimer(0)
+	stopTimer := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+
+	var (
+		last      time.Time // the time of the last Tick, zero when not animating
+		next      time.Time // when the next Tick is due
+		waitFrame bool      // whether the frame after the last Tick wasn't flushed yet
+	)
+
+	for {
+		stopTimer()
+		if c.isAnimating() {
+			now := time.Now()
+			late := !now.Before(next.Add(interval)) // no frame came, don't wait any longer
+			switch {
+			case last.IsZero():
+				in <- Tick{Time: now}
+				last, next = now, now.Add(interval)
+				waitFrame = frames != nil
+			case !now.Before(next) && (!waitFrame || late):
+				in <- Tick{Time: now, Delta: now.Sub(last)}
+				last, next = now, next.Add(interval)
+				if !next.After(now) {
+					next = now.Add(interval) // skip the missed Ticks instead of catching up
+				}
+				waitFrame = frames != nil
+			}
+			if now.Before(next) {
+				timer.Reset(next.Sub(now))
+			} else {
+				timer.Reset(next.Add(interval).Sub(now))
+			}
+		} else {
+			last, waitFrame = time.Time{}, false
+		}
+
+		select {
+		case e, ok := <-env.Events():
+			if !ok {
+				stopTimer()
+				return
+			}
+			in <- e
+		case _, ok := <-frames:
+			if !ok {
+				frames = nil
+			}
+			waitFrame = false
+		case <-c.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+type clockEnv struct {
+	events <-chan gui.Event
+	draw   chan<- func(draw.Image) image.Rectangle
+}
+
+func (ce *clockEnv) Events() <-chan gui.Event                      { return ce.events }
+func (ce *clockEnv) Draw() chan<- func(draw.Image) image.Rectangle { return ce.draw }
@@ -0,0 +1,141 @@
+package anim_test
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/anim"
+	"github.com/faiface/gui/headless"
+)
+
+// framer is a headless Env reporting frames when the test sends them.
+type framer struct {
+	*headless.Env
+	frames chan time.Time
+}
+
+func (f *framer) Frames() <-chan time.Time { return f.frames }
+
+func newClock(opts ...anim.Option) (*anim.Clock, gui.Env) {
+	clock, env := anim.New(headless.New(image.Rect(0, 0, 10, 10)), opts...)
+	<-env.Events() // the Resize
+	return clock, env
+}
+
+// nextTick returns the next Tick, skipping the other events, or fails the test after a second.
+func nextTick(t *testing.T, env gui.Env) anim.Tick {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e := <-env.Events():
+			if tick, ok := e.(anim.Tick); ok {
+				return tick
+			}
+		case <-timeout:
+			t.Fatal("no Tick within 1s")
+		}
+	}
+}
+
+// noTicks fails the test if a Tick comes within the duration.
+func noTicks(t *testing.T, env gui.Env, d time.Duration) {
+	t.Helper()
+	timeout := time.After(d)
+	for {
+		select {
+		case e := <-env.Events():
+			if _, ok := e.(anim.Tick); ok {
+				t.Fatalf("got %v while not animating", e)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// drain receives the events already sent.
+func drain(env gui.Env) {
+	for {
+		select {
+		case <-env.Events():
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestIdle(t *testing.T) {
+	_, env := newClock(anim.Rate(1000))
+	noTicks(t, env, 100*time.Millisecond)
+	close(env.Draw())
+}
+
+func TestStartStop(t *testing.T) {
+	clock, env := newClock(anim.Rate(100))
+
+	clock.Start()
+	if tick := nextTick(t, env); tick.Delta != 0 {
+		t.Errorf("the first Tick has Delta %v, want 0", tick.Delta)
+	}
+	if tick := nextTick(t, env); tick.Delta <= 0 {
+		t.Errorf("the second Tick has Delta %v, want the time since the first", tick.Delta)
+	}
+
+	// Start and Stop nest
+	clock.Start()
+	clock.Stop()
+	nextTick(t, env)
+	clock.Stop()
+	drain(env)
+	noTicks(t, env, 100*time.Millisecond)
+
+	// more Stops than Starts don't make the next Start count less
+	clock.Stop()
+	clock.Start()
+	if tick := nextTick(t, env); tick.Delta != 0 {
+		t.Errorf("the first Tick after a pause has Delta %v, want 0", tick.Delta)
+	}
+	clock.Stop()
+	close(env.Draw())
+}
+
+func TestInvalidRate(t *testing.T) {
+	for _, rate := range []float64{0, -60, 1e100} {
+		clock, env := newClock(anim.Rate(rate))
+		clock.Start()
+		nextTick(t, env)
+		// the default of 60 per second applies
+		if tick := nextTick(t, env); tick.Delta < 10*time.Millisecond {
+			t.Errorf("Rate(%v): Ticks %v apart, want about 1/60s", rate, tick.Delta)
+		}
+		clock.Stop()
+		close(env.Draw())
+	}
+}
+
+func TestFramer(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	f := &framer{headless.New(image.Rect(0, 0, 10, 10)), make(chan time.Time)}
+	clock, env := anim.New(f, anim.Rate(float64(time.Second/interval)))
+	<-env.Events()
+
+	// a frame flushed after each Tick keeps the Ticks on schedule
+	clock.Start()
+	nextTick(t, env)
+	for i := 0; i < 3; i++ {
+		f.frames <- time.Now()
+		if tick := nextTick(t, env); tick.Delta > interval*3/2 {
+			t.Errorf("Ticks %v apart with frames, want %v", tick.Delta, interval)
+		}
+	}
+
+	// without a frame, the next Tick waits one more interval
+	if tick := nextTick(t, env); tick.Delta < interval*3/2 {
+		t.Errorf("Ticks %v apart without a frame, want %v", tick.Delta, 2*interval)
+	}
+	clock.Stop()
+	close(env.Draw())
+}
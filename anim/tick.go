@@ -0,0 +1,42 @@
+package anim
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+// Tick is an event produced by a Clock while something is animating.
+//
+// Time is the time of the tick and Delta is the time elapsed since the previous tick, or zero
+// for the first tick after the Clock started animating.
+type Tick struct {
+	Time  time.Time
+	Delta time.Duration
+}
+
+func (t Tick) String() string {
+	return fmt.Sprintf("anim/tick/%d/%d", t.Time.UnixNano(), int64(t.Delta))
+}
+
+func init() {
+	gui.RegisterEvent("anim/tick/", parseTick)
+}
+
+func parseTick(s string) (gui.Event, error) {
+	fields, err := gui.SplitEvent(s, 4)
+	if err != nil {
+		return nil, fmt.Errorf("anim: %v", err)
+	}
+	// nanoseconds don't fit into an int on 32-bit platforms, so no ParseEventInts
+	var ns [2]int64
+	for i, f := range fields[2:] {
+		ns[i], err = strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("anim: event %q: invalid integer %q: %w", s, f, err)
+		}
+	}
+	return Tick{Time: time.Unix(0, ns[0]), Delta: time.Duration(ns[1])}, nil
+}
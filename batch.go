@@ -0,0 +1,60 @@
+package gui
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Batch composes the draw functions into a single one, which calls them in order and returns
+// the union of the rectangles they returned.
+//
+// The backends flush the changes to the screen once no draw function comes for a moment, about
+// a millisecond, and Mux passes the draw functions on one by one. So when a component sends
+// several draw functions that make up one update, such as a background and the text on top of
+// it, and the later ones come a bit late, the screen may show a half-drawn state. A Batch gets
+// executed as a single draw function, so no flush can come in the middle of it.
+func Batch(ds ...func(draw.Image) image.Rectangle) func(draw.Image) image.Rectangle {
+	return func(drw draw.Image) image.Rectangle {
+		var r image.Rectangle
+		for _, d := range ds {
+			r = r.Union(d(drw))
+		}
+		return r
+	}
+}
+
+// Tx is a transaction collecting draw functions to send to an Env as a single Batch.
+//
+//	tx := gui.Begin(env)
+//	tx.Draw(background)
+//	tx.Draw(text)
+//	tx.Commit()
+type Tx struct {
+	env Env
+	ds  []func(draw.Image) image.Rectangle
+}
+
+// Begin starts a transaction on the Env.
+func Begin(env Env) *Tx {
+	return &Tx{env: env}
+}
+
+// Draw adds the draw function to the transaction. Nothing gets drawn until Commit.
+func (tx *Tx) Draw(d func(draw.Image) image.Rectangle) {
+	tx.ds = append(tx.ds, d)
+}
+
+// Commit sends all the draw functions added to the transaction to the Env as a single Batch,
+// unless there are none. The transaction is empty afterwards and can be used again.
+func (tx *Tx) Commit() {
+	if len(tx.ds) == 0 {
+		return
+	}
+	tx.env.Draw() <- Batch(tx.ds...)
+	tx.ds = nil
+}
+
+// Rollback discards all the draw functions added to the transaction.
+func (tx *Tx) Rollback() {
+	tx.ds = nil
+}
@@ -0,0 +1,89 @@
+package gui_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/headless"
+)
+
+// mark returns a draw function recording its name and returning r.
+func mark(calls *[]string, name string, r image.Rectangle) func(draw.Image) image.Rectangle {
+	return func(draw.Image) image.Rectangle {
+		*calls = append(*calls, name)
+		return r
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var calls []string
+	b := gui.Batch(
+		mark(&calls, "background", image.Rect(0, 0, 10, 10)),
+		mark(&calls, "nothing", image.ZR),
+		mark(&calls, "text", image.Rect(5, 5, 20, 15)),
+	)
+	if len(calls) != 0 {
+		t.Fatalf("Batch called %q before being executed", calls)
+	}
+	if got, want := b(image.NewRGBA(image.Rect(0, 0, 30, 30))), image.Rect(0, 0, 20, 15); got != want {
+		t.Errorf("Batch returned %v, want the union %v", got, want)
+	}
+	if want := []string{"background", "nothing", "text"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("called %q, want %q in order", calls, want)
+	}
+
+	if got := gui.Batch()(image.NewRGBA(image.Rect(0, 0, 30, 30))); !got.Empty() {
+		t.Errorf("an empty Batch returned %v", got)
+	}
+}
+
+func TestTx(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	root := headless.New(image.Rect(0, 0, 10, 10))
+	defer close(root.Draw())
+	drawn := make(chan struct{}, 1)
+	done := func(draw.Image) image.Rectangle {
+		drawn <- struct{}{}
+		return image.ZR
+	}
+
+	tx := gui.Begin(root)
+	tx.Draw(fill(red))
+	tx.Draw(func(drw draw.Image) image.Rectangle {
+		r := image.Rect(0, 0, 5, 5)
+		draw.Draw(drw, r, &image.Uniform{blue}, image.ZP, draw.Src)
+		return r
+	})
+	tx.Draw(done)
+	if root.Draws() != 0 {
+		t.Fatal("the transaction drew before Commit")
+	}
+	tx.Commit()
+	<-drawn
+	if n := root.Draws(); n != 1 {
+		t.Errorf("Commit sent %d draw functions, want a single one", n)
+	}
+	img := root.Image()
+	if img.RGBAAt(0, 0) != blue || img.RGBAAt(9, 9) != red {
+		t.Errorf("got %v and %v, want the draw functions executed in order", img.RGBAAt(0, 0), img.RGBAAt(9, 9))
+	}
+
+	// the transaction is empty after Commit and Rollback, and committing nothing sends nothing
+	tx.Commit()
+	tx.Draw(fill(blue))
+	tx.Rollback()
+	tx.Commit()
+	tx.Draw(done)
+	tx.Commit()
+	<-drawn
+	if n := root.Draws(); n != 2 {
+		t.Errorf("%d draw functions executed, want 2", n)
+	}
+	if got := root.Image().RGBAAt(9, 9); got != red {
+		t.Errorf("got %v, want the rolled back fill discarded", got)
+	}
+}
@@ -0,0 +1,92 @@
+package gui
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Child is an Env created by Mux.MakeChild. Unlike the Envs created by Mux.MakeEnv, which can
+// only remove themselves by closing their Draw() channel, a Child gives the code that created it
+// control over it: it can close the Child, wait for it to exit and move it around.
+//
+// A Child can be used as the root Env of another Mux. That way, a panel can own a subtree of
+// Envs: closing the Child closes all the Envs created by the nested Mux, and the Child is done
+// once they all closed their Draw() channels, or once the master Env of the nested Mux did.
+type Child struct {
+	mux *Mux
+	m   *muxEnv
+}
+
+// MakeChild creates a new virtual Env just like MakeEnv, but returns it as a Child.
+func (mux *Mux) MakeChild(opts ...EnvOption) *Child {
+	c := &Child{mux: mux, m: mux.makeEnv(false, opts)}
+	c.m.self = c
+	return c
+}
+
+func (c *Child) Events() <-chan Event                          { return c.m.events }
+func (c *Child) Draw() chan<- func(draw.Image) image.Rectangle { return c.m.draw }
+
+// Close removes the Child from the Mux and closes its Events() channel, just as if the Child
+// closed its Draw() channel. Anything the Child draws afterwards gets ignored. The component
+// running in the Child is still expected to close its Draw() channel, see Done.
+//
+// Calling Close multiple times is fine.
+func (c *Child) Close() {
+	c.mux.mu.Lock()
+	defer c.mux.mu.Unlock()
+	c.mux.remove(c.m)
+}
+
+// Done returns a channel that gets closed once the Child closed its Draw() channel and got
+// removed from the Mux.
+func (c *Child) Done() <-chan struct{} {
+	return c.m.done
+}
+
+// Bounds returns the rectangle the Child is restricted to and whether it is restricted at all,
+// see the Bounds option.
+func (c *Child) Bounds() (r image.Rectangle, ok bool) {
+	c.mux.mu.Lock()
+	defer c.mux.mu.Unlock()
+	return c.m.opts.bounds, c.m.opts.bounded
+}
+
+// SetBounds restricts the Child to the rectangle r, just like the Bounds option. The Child
+// receives a Resize event reporting its new bounds and the Envs below it receive a Resize event
+// if they need to redraw the part it covered before.
+func (c *Child) SetBounds(r image.Rectangle) {
+	mux, m := c.mux, c.m
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if m.isClosed() {
+		return
+	}
+
+	before := m.area(mux)
+	if m.opts.bounded {
+		mux.index.remove(m, m.opts.bounds)
+	} else {
+		mux.unbounded = removeEnv(mux.unbounded, m)
+	}
+	m.opts.bounded, m.opts.bounds = true, r
+	mux.index.insert(m, r)
+
+	if mux.compositing {
+		// the buffer gets recreated with the new bounds when the Child draws
+		mux.recomposite(m.layerBounds())
+		m.buf = nil
+		mux.post(m, m.resize())
+	} else {
+		mux.expose(before.Union(r))
+	}
+	mux.updateHover()
+}
+
+// unwrapEnv returns the Env created by a Mux that the Env passed to its methods stands for.
+func unwrapEnv(env Env) Env {
+	if c, ok := env.(*Child); ok {
+		return c.m
+	}
+	return env
+}
@@ -0,0 +1,157 @@
+package gui_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/win"
+)
+
+// fill returns a draw function trying to fill everything with the color.
+func fill(c color.Color) func(draw.Image) image.Rectangle {
+	return func(drw draw.Image) image.Rectangle {
+		draw.Draw(drw, drw.Bounds(), &image.Uniform{c}, image.ZP, draw.Src)
+		return drw.Bounds()
+	}
+}
+
+// closed fails the test unless the channel gets closed within a second.
+func closed(t *testing.T, what string, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatalf("%s not closed within 1s", what)
+	}
+}
+
+// eventsClosed fails the test unless the Events() channel of the Env gets closed within a second.
+func eventsClosed(t *testing.T, env gui.Env) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-env.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Events() not closed within 1s")
+		}
+	}
+}
+
+func TestChildClose(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	c := mux.MakeChild()
+
+	c.Close()
+	c.Close()
+	eventsClosed(t, c)
+	select {
+	case <-c.Done():
+		t.Fatal("Done closed before the Child closed its Draw() channel")
+	default:
+	}
+
+	// the draw functions of a closed Child get ignored
+	c.Draw() <- fill(color.RGBA{255, 0, 0, 255})
+	close(c.Draw())
+	closed(t, "Done()", c.Done())
+	if root.Draws() != 0 {
+		t.Errorf("the root Env executed %d draw functions of the closed Child", root.Draws())
+	}
+}
+
+func TestChildDone(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	c := mux.MakeChild()
+
+	drawSync(t, c, fill(color.RGBA{255, 0, 0, 255}))
+	close(c.Draw())
+	closed(t, "Done()", c.Done())
+	eventsClosed(t, c)
+
+	// the Child is gone from the Mux, so it doesn't receive anything and Close does nothing
+	c.Close()
+	root.Send(win.MoMove{Point: image.Pt(1, 1)})
+	mux.Raise(c)
+}
+
+func TestChildSetBounds(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	below := mux.MakeEnv()
+	c := mux.MakeChild()
+
+	if _, ok := c.Bounds(); ok {
+		t.Error("a Child created without bounds has bounds")
+	}
+	eventsUntil(t, below, "resize/0/0/100/100")
+	eventsUntil(t, c, "resize/0/0/100/100")
+
+	c.SetBounds(image.Rect(10, 10, 30, 30))
+	if r, ok := c.Bounds(); !ok || r != image.Rect(10, 10, 30, 30) {
+		t.Errorf("Bounds() = %v, %v after SetBounds", r, ok)
+	}
+	eventsUntil(t, c, "resize/10/10/30/30")
+	eventsUntil(t, below, "resize/0/0/100/100") // to redraw what the Child doesn't cover now
+
+	root.Send(win.MoDown{Point: image.Pt(20, 20), Button: win.ButtonLeft})
+	root.Send(win.MoUp{Point: image.Pt(20, 20), Button: win.ButtonLeft})
+	eventsUntil(t, c, "mo/up/20/20/left")
+
+	// after moving, the old bounds don't route to the Child anymore
+	c.SetBounds(image.Rect(50, 50, 70, 70))
+	eventsUntil(t, c, "resize/50/50/70/70")
+	root.Send(win.MoDown{Point: image.Pt(20, 20), Button: win.ButtonRight})
+	root.Send(win.MoDown{Point: image.Pt(60, 60), Button: win.ButtonMiddle})
+	if got := eventsUntil(t, c, "mo/down/60/60/middle"); contains(got, "mo/down/20/20/right") {
+		t.Errorf("the Child received a press at its old bounds, got %q", got)
+	}
+	if got := eventsUntil(t, below, "mo/down/20/20/right"); contains(got, "mo/down/20/20/left") {
+		t.Errorf("the Env below the Child received a press over it, got %q", got)
+	}
+}
+
+func TestChildRaise(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	a := mux.MakeChild(gui.Bounds(image.Rect(10, 10, 50, 50)))
+	b := mux.MakeChild(gui.Bounds(image.Rect(30, 30, 70, 70)))
+	eventsUntil(t, a, "resize/10/10/50/50")
+	eventsUntil(t, b, "resize/30/30/70/70")
+
+	drawSync(t, a, fill(red))
+	drawSync(t, b, fill(blue))
+	if got := root.Image().RGBAAt(40, 40); got != blue {
+		t.Errorf("the overlap is %v before raising, want b on top", got)
+	}
+
+	// the raised Child gets a Resize to redraw itself on the top, and covers b from now on
+	mux.Raise(a)
+	eventsUntil(t, a, "resize/10/10/50/50")
+	drawSync(t, a, fill(red))
+	drawSync(t, b, fill(blue))
+	if got := root.Image().RGBAAt(40, 40); got != red {
+		t.Errorf("the overlap is %v after raising a, want a on top", got)
+	}
+	root.Send(win.MoDown{Point: image.Pt(40, 40), Button: win.ButtonLeft})
+	root.Send(win.MoUp{Point: image.Pt(40, 40), Button: win.ButtonLeft})
+	eventsUntil(t, a, "mo/up/40/40/left")
+
+	// lowering it again makes b redraw the part a covered
+	mux.Lower(a)
+	eventsUntil(t, b, "resize/30/30/70/70")
+	drawSync(t, b, fill(blue))
+	if got := root.Image().RGBAAt(40, 40); got != blue {
+		t.Errorf("the overlap is %v after lowering a, want b on top", got)
+	}
+	root.Send(win.MoDown{Point: image.Pt(40, 40), Button: win.ButtonRight})
+	if got := eventsUntil(t, b, "mo/down/40/40/right"); contains(got, "mo/down/40/40/left") {
+		t.Errorf("b received the press while covered, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// clipImage returns a draw.Image that only allows drawing inside the rectangle r of img. Its
+// coordinates are moved, so that the point origin of img becomes (0, 0).
+//
+// The result shares pixels with img. For *image.RGBA, the result is an *image.RGBA too, so that
+// the fast paths of the image/draw package still apply.
+func clipImage(img draw.Image, r image.Rectangle, origin image.Point) draw.Image {
+	r = r.Intersect(img.Bounds())
+
+	if rgba, ok := img.(*image.RGBA); ok {
+		sub := rgba.SubImage(r).(*image.RGBA)
+		return &image.RGBA{
+			Pix:    sub.Pix,
+			Stride: sub.Stride,
+			Rect:   sub.Rect.Sub(origin),
+		}
+	}
+
+	return &clippedImage{img: img, r: r, origin: origin}
+}
+
+type clippedImage struct {
+	img    draw.Image
+	r      image.Rectangle
+	origin image.Point
+}
+
+func (ci *clippedImage) ColorModel() color.Model { return ci.img.ColorModel() }
+func (ci *clippedImage) Bounds() image.Rectangle { return ci.r.Sub(ci.origin) }
+
+func (ci *clippedImage) At(x, y int) color.Color {
+	p := image.Pt(x, y).Add(ci.origin)
+	if !p.In(ci.r) {
+		return color.Transparent
+	}
+	return ci.img.At(p.X, p.Y)
+}
+
+func (ci *clippedImage) Set(x, y int, c color.Color) {
+	p := image.Pt(x, y).Add(ci.origin)
+	if !p.In(ci.r) {
+		return
+	}
+	ci.img.Set(p.X, p.Y, c)
+}
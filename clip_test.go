@@ -0,0 +1,55 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestClipImage(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	r := image.Rect(10, 20, 30, 40)
+	origin := image.Pt(10, 20)
+
+	for _, img := range []draw.Image{
+		image.NewRGBA(image.Rect(0, 0, 50, 50)),
+		image.NewNRGBA(image.Rect(0, 0, 50, 50)), // not *image.RGBA, so no fast path
+	} {
+		clipped := clipImage(img, r, origin)
+		if got, want := clipped.Bounds(), image.Rect(0, 0, 20, 20); got != want {
+			t.Errorf("%T: clipped bounds %v, want %v", img, got, want)
+		}
+
+		// filling everything, including the points outside of the bounds, only changes r
+		big := image.Rect(-100, -100, 100, 100)
+		draw.Draw(clipped, big, &image.Uniform{red}, image.ZP, draw.Src)
+		for y := -5; y < 25; y++ {
+			for x := -5; x < 25; x++ {
+				clipped.Set(x, y, red)
+			}
+		}
+
+		for y := 0; y < 50; y++ {
+			for x := 0; x < 50; x++ {
+				got := color.RGBAModel.Convert(img.At(x, y))
+				want := color.Color(color.RGBA{})
+				if image.Pt(x, y).In(r) {
+					want = red
+				}
+				if got != want {
+					t.Fatalf("%T: pixel (%d, %d) is %v, want %v", img, x, y, got, want)
+				}
+			}
+		}
+		if got := color.RGBAModel.Convert(clipped.At(0, 0)); got != red {
+			t.Errorf("%T: clipped (0, 0) is %v, want the pixel at the origin", img, got)
+		}
+	}
+
+	// the bounds of the image clip too
+	clipped := clipImage(image.NewRGBA(image.Rect(0, 0, 50, 50)), image.Rect(40, 40, 60, 60), image.ZP)
+	if got, want := clipped.Bounds(), image.Rect(40, 40, 50, 50); got != want {
+		t.Errorf("clipped bounds %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package gui_test
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/win"
+)
+
+// sendAll sends the events before receiving any of them, so the consumer is as far behind as it
+// can be, and returns the received events.
+func sendAll(makeChan func() (<-chan gui.Event, chan<- gui.Event), events []gui.Event) []string {
+	out, in := makeChan()
+	for _, e := range events {
+		in <- e
+	}
+	close(in)
+	var got []string
+	for e := range out {
+		got = append(got, e.String())
+	}
+	return got
+}
+
+func TestMakeCoalescingEventsChan(t *testing.T) {
+	events := []gui.Event{
+		win.MoMove{Point: image.Pt(1, 1)},
+		win.MoMove{Point: image.Pt(2, 2)},
+		win.MoScroll{Point: image.Pt(0, 1)},
+		win.MoScroll{Point: image.Pt(0, 2)},
+		win.MoScroll{Point: image.Pt(1, -1)},
+		win.MoDown{Point: image.Pt(2, 2), Button: win.ButtonLeft},
+		win.MoMove{Point: image.Pt(3, 3)},
+		win.MoMove{Point: image.Pt(4, 4)},
+		win.KbType{Rune: 'a'},
+		win.MoMove{Point: image.Pt(5, 5)},
+		gui.Resize{Rectangle: image.Rect(0, 0, 1, 1)},
+		gui.Resize{Rectangle: image.Rect(0, 0, 2, 2)},
+		win.KbType{Rune: 'b'},
+		win.MoScroll{Point: image.Pt(0, 1)},
+	}
+
+	got := sendAll(gui.MakeCoalescingEventsChan, events)
+	want := []string{
+		"mo/move/2/2",
+		"mo/scroll/1/2",
+		"mo/down/2/2/left",
+		"mo/move/4/4",
+		"kb/type/97",
+		"mo/move/5/5",
+		"resize/0/0/2/2",
+		"kb/type/98",
+		"mo/scroll/0/1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coalescing channel delivered\n%q\nwant\n%q", got, want)
+	}
+
+	got = sendAll(gui.MakeEventsChan, events)
+	if len(got) != len(events) {
+		t.Errorf("MakeEventsChan delivered %d of %d events, it must not merge any", len(got), len(events))
+	}
+}
+
+func TestMakeCoalescingEventsChanOrder(t *testing.T) {
+	// every event carries its position in the sequence, in X or in the rune
+	const n = 20000
+	rnd := rand.New(rand.NewSource(1))
+	events := make([]gui.Event, n)
+	for i := range events {
+		switch rnd.Intn(10) {
+		case 0:
+			events[i] = win.MoDown{Point: image.Pt(i, 0), Button: win.ButtonLeft}
+		case 1:
+			events[i] = win.KbType{Rune: rune(i)}
+		default:
+			events[i] = win.MoMove{Point: image.Pt(i, 0)}
+		}
+	}
+
+	out, in := gui.MakeCoalescingEventsChan()
+	go func() {
+		for _, e := range events {
+			in <- e
+		}
+		close(in)
+	}()
+
+	// a slow consumer lets runs of moves pile up and merge
+	received := make(map[int]bool)
+	last := -1
+	for e := range out {
+		var i int
+		switch e := e.(type) {
+		case win.MoDown:
+			i = e.X
+		case win.KbType:
+			i = int(e.Rune)
+		case win.MoMove:
+			i = e.X
+		}
+		if i <= last {
+			t.Fatalf("received %v after the event number %d", e, last)
+		}
+		last = i
+		received[i] = true
+		if rnd.Intn(100) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// only a move followed by another move may be merged away
+	for i, e := range events {
+		_, move := e.(win.MoMove)
+		nextMove := false
+		if i+1 < len(events) {
+			_, nextMove = events[i+1].(win.MoMove)
+		}
+		if (!move || !nextMove) && !received[i] {
+			t.Errorf("event number %d, %v, got lost", i, e)
+		}
+	}
+}
+
+// benchmarkCoalescing sends b.N events in bursts of mouse moves with a click every 16 events,
+// receiving each burst before sending the next one.
+func benchmarkCoalescing(b *testing.B, makeChan func() (<-chan gui.Event, chan<- gui.Event), burst int) {
+	b.ReportAllocs()
+	events := make([]gui.Event, burst)
+	for i := range events {
+		events[i] = win.MoMove{Point: image.Pt(i, i)}
+		if i%16 == 15 {
+			events[i] = win.MoDown{Point: image.Pt(i, i), Button: win.ButtonLeft}
+		}
+	}
+	var end gui.Event = win.KbType{Rune: 'x'}
+	out, in := makeChan()
+	defer close(in)
+
+	b.ResetTimer()
+	for sent := 0; sent < b.N; sent += burst {
+		for _, e := range events {
+			in <- e
+		}
+		in <- end
+		for e := range out {
+			if e == end {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkMakeCoalescingEventsChan(b *testing.B) {
+	for _, burst := range []int{16, 1024} {
+		b.Run(fmt.Sprintf("coalescing/burst=%d", burst), func(b *testing.B) {
+			benchmarkCoalescing(b, gui.MakeCoalescingEventsChan, burst)
+		})
+		b.Run(fmt.Sprintf("plain/burst=%d", burst), func(b *testing.B) {
+			benchmarkCoalescing(b, gui.MakeEventsChan, burst)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// NewCompositingMux creates a new Mux just like NewMux, except that the Envs created by the Mux
+// don't draw directly to the root Env. Instead, each Env draws to its own retained buffer and
+// the Mux composites the buffers, from the bottom of the stack to the top, onto the drawing area
+// of the root Env. Only the rectangles returned by the draw functions get composited again.
+//
+// Compositing makes it possible to change the opacity and the offset of an Env at any time, see
+// Mux.SetOpacity and Mux.SetOffset, at the cost of extra memory and copying.
+func NewCompositingMux(env Env) (mux *Mux, master Env) {
+	return newMux(env, true)
+}
+
+// SetOpacity sets the opacity of the Env, which must have been created by the Mux, in the range
+// from 0 (invisible) to 1 (opaque, the default). It only has effect on a Mux created by
+// NewCompositingMux.
+func (mux *Mux) SetOpacity(env Env, opacity float64) {
+	if opacity < 0 {
+		opacity = 0
+	}
+	if opacity > 1 {
+		opacity = 1
+	}
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	i := mux.stackIndex(env)
+	if i == -1 || !mux.compositing {
+		return
+	}
+	m := mux.stack[i]
+	m.opacity = opacity
+	mux.recomposite(m.layerBounds())
+}
+
+// SetOffset moves the content of the Env, which must have been created by the Mux, by offset
+// when compositing it. The offset is only visual, it does not change the coordinates of the
+// events received by the Env. It only has effect on a Mux created by NewCompositingMux.
+func (mux *Mux) SetOffset(env Env, offset image.Point) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	i := mux.stackIndex(env)
+	if i == -1 || !mux.compositing {
+		return
+	}
+	m := mux.stack[i]
+	before := m.layerBounds()
+	m.offset = offset
+	mux.recomposite(before.Union(m.layerBounds()))
+}
+
+// layerBounds returns the part of the root Env covered by the buffer of the Env, including its
+// offset. It must be called with mux.mu locked.
+func (m *muxEnv) layerBounds() image.Rectangle {
+	if m.buf == nil {
+		return image.ZR
+	}
+	return m.buf.Bounds().Add(m.offset)
+}
+
+type layer struct {
+	buf     *image.RGBA
+	opacity float64
+	offset  image.Point
+}
+
+// compositeDraw turns a draw function of the Env into a draw function drawing to its buffer and
+// compositing the changed part onto the root Env.
+func (mux *Mux) compositeDraw(m *muxEnv, d func(draw.Image) image.Rectangle) func(draw.Image) image.Rectangle {
+	return func(drw draw.Image) image.Rectangle {
+		// buffers are only ever drawn to from the root Env, so it's fine to draw to the buffer
+		// without holding the lock, the lock only protects the buffer being swapped
+		mux.mu.Lock()
+		buf := m.buf
+		bounded, area, origin := m.opts.bounded, drw.Bounds(), m.origin()
+		if bounded {
+			area = m.opts.bounds
+		}
+		mux.mu.Unlock()
+		if buf == nil || buf.Bounds() != area {
+			newBuf := image.NewRGBA(area)
+			if buf != nil {
+				draw.Draw(newBuf, buf.Bounds(), buf, buf.Bounds().Min, draw.Src)
+			}
+			buf = newBuf
+			mux.mu.Lock()
+			m.buf = buf
+			mux.mu.Unlock()
+		}
+
+		var r image.Rectangle
+		if bounded {
+			r = d(clipImage(buf, area, origin)).Add(origin)
+		} else {
+			r = d(buf)
+		}
+		r = r.Intersect(area)
+
+		mux.mu.Lock()
+		r = r.Add(m.offset)
+		mux.mu.Unlock()
+
+		return mux.composite(drw, r)
+	}
+}
+
+// composite composites the buffers of all the Envs inside the rectangle r onto the drawing area
+// of the root Env and returns the changed rectangle.
+func (mux *Mux) composite(drw draw.Image, r image.Rectangle) image.Rectangle {
+	r = r.Intersect(drw.Bounds())
+	if r.Empty() {
+		return image.ZR
+	}
+
+	mux.mu.Lock()
+	layers := make([]layer, 0, len(mux.stack))
+	for _, m := range mux.stack {
+		if m.buf != nil && m.opacity > 0 {
+			layers = append(layers, layer{m.buf, m.opacity, m.offset})
+		}
+	}
+	mux.mu.Unlock()
+
+	draw.Draw(drw, r, image.Transparent, image.ZP, draw.Src)
+	for _, l := range layers {
+		sp := r.Min.Sub(l.offset)
+		if l.opacity >= 1 {
+			draw.Draw(drw, r, l.buf, sp, draw.Over)
+			continue
+		}
+		mask := &image.Uniform{color.Alpha{uint8(l.opacity*255 + 0.5)}}
+		draw.DrawMask(drw, r, l.buf, sp, mask, image.ZP, draw.Over)
+	}
+
+	return r
+}
+
+// recomposite asks the root Env to composite the rectangle r again. It must be called with
+// mux.mu locked and it doesn't block.
+func (mux *Mux) recomposite(r image.Rectangle) {
+	if r.Empty() {
+		return
+	}
+	mux.open++ // Wait waits for this goroutine too
+	go func() {
+		select {
+		case mux.draw <- func(drw draw.Image) image.Rectangle {
+			return mux.composite(drw, r)
+		}:
+		case <-mux.closing:
+		}
+		mux.mu.Lock()
+		mux.open--
+		mux.checkFinished()
+		mux.mu.Unlock()
+	}()
+}
@@ -0,0 +1,66 @@
+package gui_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/guitest"
+)
+
+type layer struct {
+	clr  color.RGBA
+	opts []gui.EnvOption
+}
+
+// composited runs a compositing Mux with an Env for each of the layers, from the bottom to the
+// top. Each Env tries to fill everything with its color, but only changes its own buffer. The
+// Envs draw from the top to the bottom, so that the stacking is up to the compositing.
+func composited(layers []layer, setup func(*gui.Mux, []gui.Env)) func(gui.Env) {
+	return func(env gui.Env) {
+		mux, master := gui.NewCompositingMux(env)
+		envs := make([]gui.Env, len(layers))
+		for i, l := range layers {
+			envs[i] = mux.MakeEnv(l.opts...)
+		}
+		if setup != nil {
+			setup(mux, envs)
+		}
+		for i := len(layers) - 1; i >= 0; i-- {
+			clr := layers[i].clr
+			envs[i].Draw() <- func(drw draw.Image) image.Rectangle {
+				everything := image.Rect(-1000, -1000, 1000, 1000)
+				draw.Draw(drw, everything, &image.Uniform{clr}, image.ZP, draw.Src)
+				return everything
+			}
+		}
+
+		for range master.Events() {
+		}
+		for _, env := range envs {
+			close(env.Draw())
+		}
+		close(master.Draw())
+		mux.Wait()
+	}
+}
+
+func TestCompositingMux(t *testing.T) {
+	layers := []layer{
+		{color.RGBA{255, 0, 0, 255}, []gui.EnvOption{gui.Bounds(image.Rect(4, 4, 24, 24))}},
+		{color.RGBA{0, 0, 255, 255}, []gui.EnvOption{gui.Bounds(image.Rect(14, 14, 34, 34)), gui.Local()}},
+		{color.RGBA{0, 255, 0, 255}, []gui.EnvOption{gui.Bounds(image.Rect(30, 2, 38, 10))}},
+	}
+	guitest.Snapshot(t, "testdata/composite.png", image.Rect(0, 0, 40, 40),
+		composited(layers, nil), nil)
+
+	// the layer in the middle is half transparent and moved by an offset
+	translucent := func(mux *gui.Mux, envs []gui.Env) {
+		mux.SetOpacity(envs[1], 0.5)
+		mux.SetOffset(envs[1], image.Pt(2, 0))
+	}
+	guitest.Snapshot(t, "testdata/composite_translucent.png", image.Rect(0, 0, 40, 40),
+		composited(layers, translucent), nil, guitest.Tolerance(1))
+}
@@ -0,0 +1,104 @@
+package gui
+
+import (
+	"context"
+	"image"
+	"image/draw"
+)
+
+// WithContext returns an Env that forwards the events and the draw functions of env, except
+// that its Events() channel gets closed when ctx is done. The component using the Env should
+// then close its Draw() channel as usual, which closes the Draw() channel of env.
+func WithContext(ctx context.Context, env Env) Env {
+	out, in := MakeEventsChan()
+	drawChan := make(chan func(draw.Image) image.Rectangle)
+
+	go func() {
+		defer close(in)
+		for {
+			select {
+			case e, ok := <-env.Events():
+				if !ok {
+					return
+				}
+				in <- e
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for d := range drawChan {
+			env.Draw() <- d
+		}
+		close(env.Draw())
+	}()
+
+	return &envPair{out, drawChan}
+}
+
+// Run runs the component in the Env and blocks until it returns, then returns the error
+// returned by the component.
+//
+// The component gets a context derived from ctx, which gets cancelled when ctx is done, when
+// the Env sends a "wi/close" event, or when the Events() channel of the Env closes. The Env
+// passed to the component closes its Events() channel at the same time, see WithContext.
+//
+// The component should close its Draw() channel, just like any other component. If it returns
+// without doing so, for example because of an early error, Run closes the Draw() channel of env
+// on its behalf. Either way, nothing may be sent to the Draw() channel after the component
+// returns.
+//
+// Run fits nicely with errgroup.Group:
+//
+//	g.Go(func() error { return gui.Run(ctx, w, app) })
+func Run(ctx context.Context, env Env, component func(ctx context.Context, env Env) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	out, in := MakeEventsChan()
+	drawChan := make(chan func(draw.Image) image.Rectangle)
+	returned := make(chan struct{})
+	drawDone := make(chan struct{})
+
+	go func() {
+		defer close(in)
+		defer cancel()
+		for {
+			select {
+			case e, ok := <-env.Events():
+				if !ok {
+					return
+				}
+				in <- e
+				if e.String() == "wi/close" {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(drawDone)
+		defer close(env.Draw())
+		for {
+			select {
+			case d, ok := <-drawChan:
+				if !ok {
+					return
+				}
+				env.Draw() <- d
+			case <-returned:
+				return
+			}
+		}
+	}()
+
+	err := component(ctx, &envPair{out, drawChan})
+	close(returned)
+	<-drawDone
+	return err
+}
@@ -0,0 +1,161 @@
+package gui_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/win"
+)
+
+// rootEnv is an Env whose events are sent by the test, and which reports when its Draw()
+// channel gets closed.
+type rootEnv struct {
+	events     <-chan gui.Event
+	send       chan<- gui.Event
+	draw       chan func(draw.Image) image.Rectangle
+	drawClosed chan struct{}
+}
+
+func newRootEnv() *rootEnv {
+	events, send := gui.MakeEventsChan()
+	root := &rootEnv{
+		events:     events,
+		send:       send,
+		draw:       make(chan func(draw.Image) image.Rectangle),
+		drawClosed: make(chan struct{}),
+	}
+	go func() {
+		for range root.draw {
+		}
+		close(root.drawClosed)
+	}()
+	return root
+}
+
+func (r *rootEnv) Events() <-chan gui.Event                      { return r.events }
+func (r *rootEnv) Draw() chan<- func(draw.Image) image.Rectangle { return r.draw }
+
+func TestWithContext(t *testing.T) {
+	root := newRootEnv()
+	ctx, cancel := context.WithCancel(context.Background())
+	env := gui.WithContext(ctx, root)
+
+	root.send <- win.KbType{Rune: 'a'}
+	eventsUntil(t, env, "kb/type/97")
+	cancel()
+	eventsClosed(t, env)
+
+	close(env.Draw())
+	closed(t, "the Draw() channel of the wrapped Env", root.drawClosed)
+}
+
+func TestWithContextEventsClosed(t *testing.T) {
+	root := newRootEnv()
+	env := gui.WithContext(context.Background(), root)
+	close(root.send)
+	eventsClosed(t, env)
+	close(env.Draw())
+	closed(t, "the Draw() channel of the wrapped Env", root.drawClosed)
+}
+
+// run runs gui.Run in a goroutine and returns a channel receiving its result.
+func run(ctx context.Context, env gui.Env, component func(context.Context, gui.Env) error) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- gui.Run(ctx, env, component)
+	}()
+	return result
+}
+
+// result fails the test unless the channel receives within a second.
+func result(t *testing.T, ch <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return within 1s")
+		return nil
+	}
+}
+
+// closing is a component that records its events until its Events() channel gets closed and
+// returns the error of its context, which must be done by then.
+func closing(got *[]string) func(context.Context, gui.Env) error {
+	return func(ctx context.Context, env gui.Env) error {
+		defer close(env.Draw())
+		for e := range env.Events() {
+			*got = append(*got, e.String())
+		}
+		return ctx.Err()
+	}
+}
+
+func TestRunWiClose(t *testing.T) {
+	root := newRootEnv()
+	var got []string
+	res := run(context.Background(), root, closing(&got))
+	root.send <- win.KbType{Rune: 'a'}
+	root.send <- win.WiClose{}
+	root.send <- win.KbType{Rune: 'b'}
+	if err := result(t, res); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want the component's context.Canceled", err)
+	}
+	if len(got) != 2 || got[1] != "wi/close" {
+		t.Errorf("got %q, want the events up to wi/close", got)
+	}
+	closed(t, "the Draw() channel of the Env", root.drawClosed)
+}
+
+func TestRunEventsClosed(t *testing.T) {
+	root := newRootEnv()
+	var got []string
+	res := run(context.Background(), root, closing(&got))
+	close(root.send)
+	if err := result(t, res); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want the component's context.Canceled", err)
+	}
+	closed(t, "the Draw() channel of the Env", root.drawClosed)
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	root := newRootEnv()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var got []string
+	res := run(ctx, root, closing(&got))
+	if err := result(t, res); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the component's context.DeadlineExceeded", err)
+	}
+	closed(t, "the Draw() channel of the Env", root.drawClosed)
+}
+
+func TestRunError(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	root := newRootEnv()
+	res := run(context.Background(), root, func(ctx context.Context, env gui.Env) error {
+		env.Draw() <- func(drw draw.Image) image.Rectangle { return image.ZR }
+		close(env.Draw())
+		return errBroken
+	})
+	if err := result(t, res); err != errBroken {
+		t.Errorf("got %v, want the error of the component", err)
+	}
+	closed(t, "the Draw() channel of the Env", root.drawClosed)
+
+	// returning early without closing the Draw() channel must not hang Run
+	root = newRootEnv()
+	res = run(context.Background(), root, func(ctx context.Context, env gui.Env) error {
+		return errBroken
+	})
+	if err := result(t, res); err != errBroken {
+		t.Errorf("got %v, want the error of the component", err)
+	}
+	closed(t, "the Draw() channel of the Env", root.drawClosed)
+}
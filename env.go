@@ -31,3 +31,11 @@ type Env interface {
 	Events() <-chan Event
 	Draw() chan<- func(draw.Image) image.Rectangle
 }
+
+type envPair struct {
+	events <-chan Event
+	draw   chan<- func(draw.Image) image.Rectangle
+}
+
+func (ep *envPair) Events() <-chan Event                          { return ep.events }
+func (ep *envPair) Draw() chan<- func(draw.Image) image.Rectangle { return ep.draw }
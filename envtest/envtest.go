@@ -0,0 +1,209 @@
+// Package envtest implements a test suite checking that an Env follows the contract described
+// in the documentation of gui.Env.
+//
+// Use it from a test of the package implementing the Env:
+//
+//	func TestConformance(t *testing.T) {
+//		envtest.RunConformance(t, func(t *testing.T) envtest.Harness {
+//			env := headless.New(image.Rect(0, 0, 640, 480))
+//			return envtest.Harness{Env: env, Send: env.Send, Shutdown: env.Close}
+//		})
+//	}
+package envtest
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+// Timeout is the time the suite waits for an Env to react before reporting a failure.
+var Timeout = 5 * time.Second
+
+// Harness describes an Env under test along with optional ways to control it from outside.
+type Harness struct {
+	// Env is the Env under test.
+	Env gui.Env
+
+	// Send injects an event into the Events() channel of the Env, just like the Env would
+	// produce it. It may be nil if the Env does not support that, in which case the checks
+	// requiring it get skipped.
+	Send func(gui.Event)
+
+	// Shutdown closes the Env from the outside, for example by closing the master Env of a Mux
+	// the Env was created by. It may be nil, in which case the checks requiring it get skipped.
+	Shutdown func()
+}
+
+// RunConformance runs all the checks of the suite, each one as a subtest. The factory gets called
+// for each check and must return a freshly created Env. It may register cleanup functions on the
+// supplied *testing.T.
+func RunConformance(t *testing.T, factory func(t *testing.T) Harness) {
+	checks := []struct {
+		name  string
+		check func(t *testing.T, h Harness)
+	}{
+		{"FirstEventIsResize", checkFirstEventIsResize},
+		{"EventsNeverBlock", checkEventsNeverBlock},
+		{"DrawWithPendingEvents", checkDrawWithPendingEvents},
+		{"CloseDrawClosesEvents", checkCloseDrawClosesEvents},
+		{"DrawAfterShutdown", checkDrawAfterShutdown},
+	}
+	for _, c := range checks {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.check(t, factory(t))
+		})
+	}
+}
+
+func checkFirstEventIsResize(t *testing.T, h Harness) {
+	defer closeAndDrain(t, h.Env)
+
+	e, ok := receive(t, h.Env)
+	if !ok {
+		t.Fatal("Events() closed before producing any event")
+	}
+	if _, ok := e.(gui.Resize); !ok {
+		t.Fatalf("first event is %q, expected a resize event", e)
+	}
+}
+
+func checkEventsNeverBlock(t *testing.T, h Harness) {
+	if h.Send == nil {
+		t.Skip("the Env does not support injecting events")
+	}
+	defer closeAndDrain(t, h.Env)
+
+	receive(t, h.Env) // the initial resize
+
+	const n = 1000
+
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < n; i++ {
+			h.Send(testEvent(i))
+		}
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(Timeout):
+		t.Fatalf("sending %d events without consuming them blocked", n)
+	}
+
+	for i := 0; i < n; {
+		e, ok := receive(t, h.Env)
+		if !ok {
+			t.Fatalf("Events() closed after %d of %d events", i, n)
+		}
+		te, ok := e.(testEvent)
+		if !ok {
+			continue // the Env may produce events of its own
+		}
+		if int(te) != i {
+			t.Fatalf("received %q, expected %q", te, testEvent(i))
+		}
+		i++
+	}
+}
+
+func checkDrawWithPendingEvents(t *testing.T, h Harness) {
+	defer closeAndDrain(t, h.Env)
+
+	// the initial resize, and possibly more events, are left unconsumed
+	if h.Send != nil {
+		for i := 0; i < 100; i++ {
+			h.Send(testEvent(i))
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		select {
+		case h.Env.Draw() <- noopDraw:
+		case <-time.After(Timeout):
+			t.Fatal("Draw() blocked while events were waiting to be consumed")
+		}
+	}
+}
+
+func checkCloseDrawClosesEvents(t *testing.T, h Harness) {
+	receive(t, h.Env) // the initial resize
+
+	select {
+	case h.Env.Draw() <- noopDraw:
+	case <-time.After(Timeout):
+		t.Fatal("Draw() blocked")
+	}
+
+	closeAndDrain(t, h.Env)
+}
+
+func checkDrawAfterShutdown(t *testing.T, h Harness) {
+	if h.Shutdown == nil {
+		t.Skip("the Env cannot be shut down from the outside")
+	}
+
+	receive(t, h.Env) // the initial resize
+
+	h.Shutdown()
+
+	// a component keeps drawing until it notices that its Events() channel got closed
+	deadline := time.After(Timeout)
+	for {
+		select {
+		case h.Env.Draw() <- noopDraw:
+			continue
+		case _, ok := <-h.Env.Events():
+			if ok {
+				continue
+			}
+		case <-deadline:
+			t.Fatal("Events() did not close after shutting down the Env")
+		}
+		break
+	}
+
+	close(h.Env.Draw())
+}
+
+// receive returns the next event from the Env, failing the test if none arrives in time.
+func receive(t *testing.T, env gui.Env) (gui.Event, bool) {
+	t.Helper()
+	select {
+	case e, ok := <-env.Events():
+		return e, ok
+	case <-time.After(Timeout):
+		t.Fatal("no event received")
+		return nil, false
+	}
+}
+
+// closeAndDrain closes the Draw() channel of the Env and checks that its Events() channel
+// gets closed subsequently.
+func closeAndDrain(t *testing.T, env gui.Env) {
+	t.Helper()
+	close(env.Draw())
+	deadline := time.After(Timeout)
+	for {
+		select {
+		case _, ok := <-env.Events():
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Events() did not close after closing Draw()")
+		}
+	}
+}
+
+func noopDraw(draw.Image) image.Rectangle { return image.ZR }
+
+type testEvent int
+
+func (te testEvent) String() string { return fmt.Sprintf("envtest/%d", int(te)) }
@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+package evdev
+
+import (
+	"image"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// absInfo is struct input_absinfo.
+type absInfo struct {
+	Value, Minimum, Maximum, Fuzz, Flat, Resolution int32
+}
+
+// eviocgabs is the EVIOCGABS(axis) ioctl, _IOR('E', 0x40 + axis, struct input_absinfo).
+func eviocgabs(axis uintptr) uintptr {
+	return 2<<30 | unsafe.Sizeof(absInfo{})<<16 | 'E'<<8 | (0x40 + axis)
+}
+
+// absRange queries the range of the X and Y axes of an absolute pointing device.
+func absRange(f *os.File) (image.Rectangle, bool) {
+	var x, y absInfo
+	for _, q := range []struct {
+		axis uintptr
+		info *absInfo
+	}{{absX, &x}, {absY, &y}} {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), eviocgabs(q.axis), uintptr(unsafe.Pointer(q.info)))
+		if errno != 0 {
+			return image.ZR, false // not an absolute pointing device
+		}
+	}
+	if x.Minimum >= x.Maximum || y.Minimum >= y.Maximum {
+		return image.ZR, false
+	}
+	return image.Rect(int(x.Minimum), int(y.Minimum), int(x.Maximum)+1, int(y.Maximum)+1), true
+}
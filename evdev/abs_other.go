@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package evdev
+
+import (
+	"image"
+	"os"
+)
+
+// absRange can only query the devices on Linux.
+func absRange(f *os.File) (image.Rectangle, bool) {
+	return image.ZR, false
+}
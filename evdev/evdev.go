@@ -0,0 +1,382 @@
+// Package evdev reads input events from the Linux input devices, such as /dev/input/event0, and
+// translates them to the events of package win. It provides input to the backends without a
+// windowing system, such as package fbdev:
+//
+//	kbd, err := evdev.Open("/dev/input/event0")
+//	...
+//	env, err := fbdev.Open("/dev/fb0", fbdev.Input(kbd.Events()))
+//
+// A Reader reads the input_event records from any io.Reader, so recorded dumps of a device can be
+// replayed, too.
+//
+// Keyboards produce KbDown, KbUp and KbRepeat events for the keys known to package win and
+// KbType events for the characters typed according to a Keymap. Mice and touchscreens move a
+// software pointer, clamped to the Bounds, and produce MoMove, MoDown, MoUp and MoScroll events.
+// A touch is reported as the left mouse button. Multi-touch devices are supported through the
+// single-touch emulation the kernel reports along with the multi-touch events.
+package evdev
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"unicode"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/win"
+)
+
+// event types and codes, from linux/input-event-codes.h
+const (
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+	evAbs = 0x03
+
+	synReport  = 0
+	synDropped = 3
+
+	relX      = 0x00
+	relY      = 0x01
+	relHWheel = 0x06
+	relWheel  = 0x08
+
+	absX = 0x00
+	absY = 0x01
+)
+
+// Option is a functional option to NewReader and Open.
+type Option func(*options)
+
+type options struct {
+	bounds    image.Rectangle
+	absRange  image.Rectangle
+	keymap    Keymap
+	timeval32 bool
+}
+
+// Bounds option sets the rectangle the software pointer moves in, usually the bounds of the Env
+// the events are for. The pointer starts in its center and gets clamped to it. Without it, the
+// pointer moves freely.
+func Bounds(r image.Rectangle) Option {
+	return func(o *options) {
+		o.bounds = r
+	}
+}
+
+// AbsRange option sets the range of the coordinates reported by an absolute pointing device,
+// such as a touchscreen, which then get scaled to the Bounds. Open queries the range from the
+// device, so this is mostly useful with NewReader.
+func AbsRange(r image.Rectangle) Option {
+	return func(o *options) {
+		o.absRange = r
+	}
+}
+
+// WithKeymap option sets the keyboard layout used to produce KbType events. The default is
+// USKeymap.
+func WithKeymap(km Keymap) Option {
+	return func(o *options) {
+		o.keymap = km
+	}
+}
+
+// Timeval32 option sets whether the records have the 8-byte timestamps of 32-bit platforms,
+// making them 16 bytes long, instead of the 16-byte timestamps of 64-bit platforms, making them
+// 24 bytes long. It defaults to the platform the program runs on and only matters for reading
+// dumps recorded elsewhere.
+func Timeval32(on bool) Option {
+	return func(o *options) {
+		o.timeval32 = on
+	}
+}
+
+// Reader translates the input_event records read from an io.Reader to events. The records are
+// read in the little-endian byte order.
+type Reader struct {
+	eventsOut <-chan gui.Event
+	eventsIn  chan<- gui.Event
+
+	mu     sync.Mutex
+	r      io.Reader
+	err    error
+	closed bool
+
+	opts options
+
+	// pointer state, applied on each SYN_REPORT
+	pos              image.Point
+	rel, abs         image.Point
+	hasAbsX, hasAbsY bool
+	scroll           image.Point
+	presses          []gui.Event
+	dropped          bool
+
+	// keyboard state
+	shift, ctrl int
+	capsLock    bool
+}
+
+// NewReader creates a Reader reading the records from r until it returns an error, such as
+// io.EOF. Then the Events() channel gets closed.
+func NewReader(r io.Reader, opts ...Option) *Reader {
+	o := options{
+		keymap:    USKeymap,
+		timeval32: strconv.IntSize == 32,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	eventsOut, eventsIn := gui.MakeEventsChan()
+	rd := &Reader{
+		eventsOut: eventsOut,
+		eventsIn:  eventsIn,
+		r:         r,
+		opts:      o,
+	}
+	if !o.bounds.Empty() {
+		rd.pos = o.bounds.Min.Add(o.bounds.Max).Div(2)
+	}
+
+	go rd.readLoop()
+
+	return rd
+}
+
+// Open opens an input device, such as "/dev/input/event0", and creates a Reader for it. On
+// Linux, the range of an absolute pointing device is queried from the device.
+func Open(path string, opts ...Option) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("evdev: %v", err)
+	}
+	if r, ok := absRange(f); ok {
+		opts = append([]Option{AbsRange(r)}, opts...)
+	}
+	return NewReader(f, opts...), nil
+}
+
+// Events returns the channel of the translated events. It gets closed when reading fails.
+func (rd *Reader) Events() <-chan gui.Event { return rd.eventsOut }
+
+// Err returns the error that stopped the reading, once the Events() channel is closed. Reaching
+// the end of the input, or closing the Reader, is not an error.
+func (rd *Reader) Err() error {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	return rd.err
+}
+
+// Close closes the underlying io.Reader, if it's an io.Closer, which stops the reading.
+func (rd *Reader) Close() error {
+	rd.mu.Lock()
+	rd.closed = true
+	rd.mu.Unlock()
+	if c, ok := rd.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (rd *Reader) readLoop() {
+	defer close(rd.eventsIn)
+
+	size := 24
+	if rd.opts.timeval32 {
+		size = 16
+	}
+	rec := make([]byte, size)
+	for {
+		if _, err := io.ReadFull(rd.r, rec); err != nil {
+			rd.mu.Lock()
+			if !rd.closed && err != io.EOF {
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					err = fmt.Errorf("truncated record")
+				}
+				rd.err = fmt.Errorf("evdev: %v", err)
+			}
+			rd.mu.Unlock()
+			return
+		}
+		b := rec[size-8:]
+		typ := binary.LittleEndian.Uint16(b[0:])
+		code := binary.LittleEndian.Uint16(b[2:])
+		value := int32(binary.LittleEndian.Uint32(b[4:]))
+		rd.handle(typ, code, value)
+	}
+}
+
+func (rd *Reader) handle(typ, code uint16, value int32) {
+	if rd.dropped {
+		// the kernel dropped some events, ignore everything until the next report
+		if typ == evSyn && code == synReport {
+			rd.dropped = false
+		}
+		return
+	}
+
+	switch typ {
+	case evSyn:
+		switch code {
+		case synReport:
+			rd.report()
+		case synDropped:
+			rd.reset()
+			rd.dropped = true
+		}
+
+	case evKey:
+		if btn, ok := buttons[code]; ok {
+			if value == 1 {
+				rd.presses = append(rd.presses, win.MoDown{Button: btn})
+			} else if value == 0 {
+				rd.presses = append(rd.presses, win.MoUp{Button: btn})
+			}
+			return
+		}
+		rd.key(code, value)
+
+	case evRel:
+		switch code {
+		case relX:
+			rd.rel.X += int(value)
+		case relY:
+			rd.rel.Y += int(value)
+		case relWheel:
+			rd.scroll.Y += int(value)
+		case relHWheel:
+			rd.scroll.X -= int(value) // the same direction as scrolling left with the X server
+		}
+
+	case evAbs:
+		switch code {
+		case absX:
+			rd.abs.X, rd.hasAbsX = int(value), true
+		case absY:
+			rd.abs.Y, rd.hasAbsY = int(value), true
+		}
+	}
+}
+
+// report sends the pointer events accumulated since the previous SYN_REPORT.
+func (rd *Reader) report() {
+	pos := rd.pos.Add(rd.rel)
+	if rd.hasAbsX {
+		pos.X = scale(rd.abs.X, rd.opts.absRange.Min.X, rd.opts.absRange.Max.X,
+			rd.opts.bounds.Min.X, rd.opts.bounds.Max.X)
+	}
+	if rd.hasAbsY {
+		pos.Y = scale(rd.abs.Y, rd.opts.absRange.Min.Y, rd.opts.absRange.Max.Y,
+			rd.opts.bounds.Min.Y, rd.opts.bounds.Max.Y)
+	}
+	if b := rd.opts.bounds; !b.Empty() {
+		pos.X = clamp(pos.X, b.Min.X, b.Max.X-1)
+		pos.Y = clamp(pos.Y, b.Min.Y, b.Max.Y-1)
+	}
+
+	if pos != rd.pos {
+		rd.pos = pos
+		rd.eventsIn <- win.MoMove{Point: pos}
+	}
+	for _, e := range rd.presses {
+		switch e := e.(type) {
+		case win.MoDown:
+			rd.eventsIn <- win.MoDown{Point: pos, Button: e.Button}
+		case win.MoUp:
+			rd.eventsIn <- win.MoUp{Point: pos, Button: e.Button}
+		}
+	}
+	if rd.scroll != image.ZP {
+		rd.eventsIn <- win.MoScroll{Point: rd.scroll}
+	}
+	rd.reset()
+}
+
+// reset forgets the pointer events accumulated since the previous SYN_REPORT.
+func (rd *Reader) reset() {
+	rd.rel, rd.scroll = image.ZP, image.ZP
+	rd.hasAbsX, rd.hasAbsY = false, false
+	rd.presses = rd.presses[:0]
+}
+
+// scale maps v from the device range [min, max) to the bounds [bmin, bmax), if both are known.
+func scale(v, min, max, bmin, bmax int) int {
+	if min >= max || bmin >= bmax {
+		return v
+	}
+	return bmin + (v-min)*(bmax-bmin)/(max-min)
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// key handles a key press (1), release (0) or repeat (2).
+func (rd *Reader) key(code uint16, value int32) {
+	switch code {
+	case keyLeftShift, keyRightShift:
+		rd.shift = modifier(rd.shift, value)
+	case keyLeftCtrl, keyRightCtrl:
+		rd.ctrl = modifier(rd.ctrl, value)
+	case keyCapsLock:
+		if value == 1 {
+			rd.capsLock = !rd.capsLock
+		}
+	}
+
+	if k, ok := keys[code]; ok {
+		switch value {
+		case 0:
+			rd.eventsIn <- win.KbUp{Key: k}
+		case 1:
+			rd.eventsIn <- win.KbDown{Key: k}
+		case 2:
+			rd.eventsIn <- win.KbRepeat{Key: k}
+		}
+	}
+	if value != 0 && rd.ctrl == 0 {
+		if r, ok := rd.rune(code); ok {
+			rd.eventsIn <- win.KbType{Rune: r}
+		}
+	}
+}
+
+// modifier counts the held down keys of a modifier, such as both Shifts. A key may have been held
+// down before the reading started, so the count doesn't go below zero.
+func modifier(held int, value int32) int {
+	switch {
+	case value == 1:
+		return held + 1
+	case value == 0 && held > 0:
+		return held - 1
+	}
+	return held
+}
+
+// rune returns the character typed by the key according to the keymap and the modifiers.
+func (rd *Reader) rune(code uint16) (rune, bool) {
+	runes, ok := rd.opts.keymap[code]
+	if !ok {
+		return 0, false
+	}
+	shift := rd.shift > 0
+	if rd.capsLock && runes[0] != runes[1] && unicode.IsLetter(runes[1]) {
+		shift = !shift
+	}
+	if shift {
+		return runes[1], true
+	}
+	return runes[0], true
+}
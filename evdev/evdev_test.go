@@ -0,0 +1,143 @@
+package evdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"strings"
+	"testing"
+)
+
+// input is an input_event record, without its timestamp.
+type input struct {
+	typ, code uint16
+	value     int32
+}
+
+var syn = input{evSyn, synReport, 0}
+
+// key codes of the keys used by the tests, from linux/input-event-codes.h
+const (
+	key1 = 2
+	keyA = 30
+	keyC = 46
+)
+
+// dump encodes the records the way the kernel does, with 16 or 24 byte long records depending on
+// the size of the timestamp.
+func dump(size int, inputs ...input) []byte {
+	var buf bytes.Buffer
+	for _, in := range inputs {
+		buf.Write(make([]byte, size-8)) // the timestamp is ignored
+		binary.Write(&buf, binary.LittleEndian, in)
+	}
+	return buf.Bytes()
+}
+
+// read returns the events read from the dump, separated by spaces.
+func read(t *testing.T, dump []byte, opts ...Option) string {
+	t.Helper()
+	rd := NewReader(bytes.NewReader(dump), opts...)
+	var events []string
+	for e := range rd.Events() {
+		events = append(events, e.String())
+	}
+	if err := rd.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	return strings.Join(events, " ")
+}
+
+func TestKeyboard(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []input
+		want   string
+	}{
+		{"letter", []input{{evKey, keyA, 1}, {evKey, keyA, 0}},
+			"kb/type/97"},
+		{"Shift", []input{{evKey, keyLeftShift, 1}, {evKey, keyA, 1}, {evKey, keyA, 0}, {evKey, key1, 1}, {evKey, keyLeftShift, 0}},
+			"kb/down/shift kb/type/65 kb/type/33 kb/up/shift"},
+		{"CapsLock", []input{{evKey, keyCapsLock, 1}, {evKey, keyCapsLock, 0}, {evKey, keyA, 1}, {evKey, key1, 1}},
+			"kb/type/65 kb/type/49"},
+		{"CapsLock and Shift", []input{{evKey, keyCapsLock, 1}, {evKey, keyCapsLock, 0}, {evKey, keyLeftShift, 1}, {evKey, keyA, 1}, {evKey, key1, 1}},
+			"kb/down/shift kb/type/97 kb/type/33"},
+		{"CapsLock twice", []input{{evKey, keyCapsLock, 1}, {evKey, keyCapsLock, 0}, {evKey, keyCapsLock, 1}, {evKey, keyCapsLock, 0}, {evKey, keyA, 1}},
+			"kb/type/97"},
+		{"repeat", []input{{evKey, keyA, 1}, {evKey, keyA, 2}, {evKey, keyEnter, 1}, {evKey, keyEnter, 2}, {evKey, keyEnter, 0}},
+			"kb/type/97 kb/type/97 kb/down/enter kb/repeat/enter kb/up/enter"},
+		{"Ctrl", []input{{evKey, keyLeftCtrl, 1}, {evKey, keyC, 1}, {evKey, keyC, 0}, {evKey, keyLeftCtrl, 0}, {evKey, keyC, 1}},
+			"kb/down/ctrl kb/up/ctrl kb/type/99"},
+		{"Shift held before reading", []input{{evKey, keyLeftShift, 0}, {evKey, keyA, 1}},
+			"kb/up/shift kb/type/97"},
+	}
+	for _, tt := range tests {
+		if got := read(t, dump(24, tt.inputs...)); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRelative(t *testing.T) {
+	// the pointer starts in the middle of the bounds, at (50, 25)
+	got := read(t, dump(24,
+		input{evRel, relX, 10}, input{evRel, relY, -5}, syn,
+		input{evKey, btnLeft, 1}, syn,
+		input{evRel, relX, 1000}, input{evRel, relY, 1000}, syn,
+		input{evKey, btnLeft, 0}, input{evRel, relWheel, -1}, input{evRel, relHWheel, 2}, syn,
+		input{evRel, relX, -1000}, input{evRel, relY, -1000}, syn,
+		input{evRel, relX, 0}, syn,
+	), Bounds(image.Rect(0, 0, 100, 50)))
+	want := "mo/move/60/20 mo/down/60/20/left mo/move/99/49 mo/up/99/49/left mo/scroll/-2/-1 mo/move/0/0"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAbsolute(t *testing.T) {
+	got := read(t, dump(24,
+		input{evKey, btnTouch, 1}, input{evAbs, absX, 4095}, input{evAbs, absY, 0}, syn,
+		input{evAbs, absX, 2048}, syn,
+		input{evAbs, absY, 4096 / 4}, syn,
+		input{evKey, btnTouch, 0}, syn,
+	), Bounds(image.Rect(0, 0, 800, 480)), AbsRange(image.Rect(0, 0, 4096, 4096)))
+	want := "mo/move/799/0 mo/down/799/0/left mo/move/400/0 mo/move/400/120 mo/up/400/120/left"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSynDropped(t *testing.T) {
+	got := read(t, dump(24,
+		input{evRel, relX, 3}, input{evSyn, synDropped, 0},
+		input{evRel, relX, 5}, input{evKey, btnLeft, 1}, syn, // incomplete, dropped
+		input{evRel, relX, 7}, syn,
+	), Bounds(image.Rect(0, 0, 100, 100)))
+	want := "mo/move/57/50"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRecordSize(t *testing.T) {
+	inputs := []input{
+		{evKey, keyLeftShift, 1}, {evKey, keyA, 1}, {evKey, keyLeftShift, 0},
+		{evRel, relX, -3}, {evKey, btnRight, 1}, syn,
+	}
+	want := "kb/down/shift kb/type/65 kb/up/shift mo/move/47/50 mo/down/47/50/right"
+	bounds := Bounds(image.Rect(0, 0, 100, 100))
+	if got := read(t, dump(24, inputs...), bounds); got != want {
+		t.Errorf("24 byte records: got\n%s\nwant\n%s", got, want)
+	}
+	if got := read(t, dump(16, inputs...), bounds, Timeval32(true)); got != want {
+		t.Errorf("16 byte records: got\n%s\nwant\n%s", got, want)
+	}
+
+	d := dump(16, inputs...)
+	rd := NewReader(bytes.NewReader(d[:len(d)-3]), Timeval32(true))
+	for range rd.Events() {
+	}
+	if err := rd.Err(); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("got error %v for a truncated record", err)
+	}
+}
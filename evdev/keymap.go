@@ -0,0 +1,92 @@
+package evdev
+
+import "github.com/faiface/gui/win"
+
+// Linux key codes, from linux/input-event-codes.h
+const (
+	keyEsc        = 1
+	keyBackspace  = 14
+	keyTab        = 15
+	keyEnter      = 28
+	keyLeftCtrl   = 29
+	keyLeftShift  = 42
+	keyRightShift = 54
+	keyLeftAlt    = 56
+	keySpace      = 57
+	keyCapsLock   = 58
+	keyKPEnter    = 96
+	keyRightCtrl  = 97
+	keyRightAlt   = 100
+	keyHome       = 102
+	keyUp         = 103
+	keyPageUp     = 104
+	keyLeft       = 105
+	keyRight      = 106
+	keyEnd        = 107
+	keyDown       = 108
+	keyPageDown   = 109
+	keyDelete     = 111
+
+	btnLeft   = 0x110
+	btnRight  = 0x111
+	btnMiddle = 0x112
+	btnTouch  = 0x14a
+)
+
+// keys reported in KbDown, KbUp and KbRepeat events
+var keys = map[uint16]win.Key{
+	keyEsc:        win.KeyEscape,
+	keyBackspace:  win.KeyBackspace,
+	keyTab:        win.KeyTab,
+	keyEnter:      win.KeyEnter,
+	keyKPEnter:    win.KeyEnter,
+	keyLeftCtrl:   win.KeyCtrl,
+	keyRightCtrl:  win.KeyCtrl,
+	keyLeftShift:  win.KeyShift,
+	keyRightShift: win.KeyShift,
+	keyLeftAlt:    win.KeyAlt,
+	keyRightAlt:   win.KeyAlt,
+	keySpace:      win.KeySpace,
+	keyHome:       win.KeyHome,
+	keyUp:         win.KeyUp,
+	keyPageUp:     win.KeyPageUp,
+	keyLeft:       win.KeyLeft,
+	keyRight:      win.KeyRight,
+	keyEnd:        win.KeyEnd,
+	keyDown:       win.KeyDown,
+	keyPageDown:   win.KeyPageDown,
+	keyDelete:     win.KeyDelete,
+}
+
+var buttons = map[uint16]win.Button{
+	btnLeft:   win.ButtonLeft,
+	btnRight:  win.ButtonRight,
+	btnMiddle: win.ButtonMiddle,
+	btnTouch:  win.ButtonLeft,
+}
+
+// Keymap maps key codes to the characters they type: the first one normally and the second
+// one with Shift held down. Caps Lock swaps them for letters.
+type Keymap map[uint16][2]rune
+
+// USKeymap is the US QWERTY keyboard layout.
+var USKeymap = Keymap{}
+
+func init() {
+	rows := []struct {
+		first         uint16
+		normal, shift string
+	}{
+		{2, "1234567890-=", "!@#$%^&*()_+"},
+		{16, "qwertyuiop[]", "QWERTYUIOP{}"},
+		{30, "asdfghjkl;'`", "ASDFGHJKL:\"~"},
+		{43, "\\zxcvbnm,./", "|ZXCVBNM<>?"},
+		{57, " ", " "},
+	}
+	for _, row := range rows {
+		normal, shift := []rune(row.normal), []rune(row.shift)
+		for i := range normal {
+			USKeymap[row.first+uint16(i)] = [2]rune{normal[i], shift[i]}
+		}
+	}
+}
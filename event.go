@@ -10,10 +10,48 @@ import (
 // This package defines only one kind of event: Resize. Other packages implementing environments
 // may implement more kinds of events. For example, the win package implements all kinds of
 // events for mouse and keyboard.
+//
+// The String() method returns a slash-separated description of the event, such as
+// "resize/0/0/640/480". Packages implementing new kinds of events can make them parseable by
+// ParseEvent by registering them using RegisterEvent.
 type Event interface {
 	String() string
 }
 
+// PointEvent is implemented by events that happen at a point of the drawing area, such as mouse
+// clicks. Mux uses it to send such events only to the Envs whose area contains the point.
+type PointEvent interface {
+	Event
+
+	// At returns the point the event happened at.
+	At() image.Point
+
+	// Translate returns the same event, but happening at the point moved by delta.
+	Translate(delta image.Point) PointEvent
+}
+
+// KeyboardEvent is implemented by keyboard events, such as those of the win package. Mux uses it
+// to send such events only to the Env that has the keyboard focus, see Mux.Focus.
+type KeyboardEvent interface {
+	Event
+
+	// KeyState returns the name of the key the event is about, such as "tab" or "shift", and
+	// whether the key is held down after the event. Events which are not about a single key,
+	// such as typing a character, return an empty name.
+	KeyState() (key string, down bool)
+}
+
+// MouseEvent is implemented by mouse events, such as those of the win package. Mux uses it to
+// send such events only to the Envs under the mouse pointer, see Bounds.
+type MouseEvent interface {
+	Event
+
+	// ButtonState returns the name of the mouse button the event is about, such as "left", and
+	// whether the button is held down after the event. Events which are not about a button, such
+	// as moving the mouse, return an empty name.
+	ButtonState() (button string, down bool)
+}
+
 // Resize is an event that happens when the environment changes the size of its drawing area.
 type Resize struct {
 	image.Rectangle
@@ -23,6 +61,24 @@ func (r Resize) String() string {
 	return fmt.Sprintf("resize/%d/%d/%d/%d", r.Min.X, r.Min.Y, r.Max.X, r.Max.Y)
 }
 
+// Coalescer is implemented by events of the "latest wins" kind, such as mouse moves or resizes,
+// whose consecutive occurrences can be merged into one without losing anything important.
+type Coalescer interface {
+	Event
+
+	// Coalesce merges the event with the previous event, which is still waiting to be delivered.
+	// It returns the merged event and true, or false if the events can't be merged.
+	Coalesce(prev Event) (Event, bool)
+}
+
+// Coalesce replaces a previous Resize with the new one.
+func (r Resize) Coalesce(prev Event) (Event, bool) {
+	if _, ok := prev.(Resize); ok {
+		return r, true
+	}
+	return nil, false
+}
+
 // MakeEventsChan implements a channel of events with an unlimited capacity. It does so
 // by creating a goroutine that queues incoming events. Sending to this channel never blocks
 // and no events get lost.
@@ -35,10 +91,36 @@ func (r Resize) String() string {
 // the purpose of delivering events. This is because the production of events is fairly
 // infrequent and should never out-run their consumption in the long term.
 func MakeEventsChan() (<-chan Event, chan<- Event) {
+	return makeEventsChan(false)
+}
+
+// MakeCoalescingEventsChan is like MakeEventsChan, except that it merges events implementing
+// the Coalescer interface with the previous queued event, if it's possible. For example,
+// a consumer falling behind only receives the latest of consecutive mouse moves, instead of all
+// of them one by one.
+//
+// Only consecutive events get merged, so the order relative to other events, such as mouse
+// clicks, is preserved. Since runs of such events take a constant space in the queue, the queue
+// only grows with the number of other events.
+func MakeCoalescingEventsChan() (<-chan Event, chan<- Event) {
+	return makeEventsChan(true)
+}
+
+func makeEventsChan(coalesce bool) (<-chan Event, chan<- Event) {
 	out, in := make(chan Event), make(chan Event)
 
 	go func() {
-		var queue []Event
+		var queue eventQueue
+
+		push := func(x Event) {
+			if c, ok := x.(Coalescer); ok && coalesce && queue.len() > 0 {
+				if merged, ok := c.Coalesce(*queue.back()); ok {
+					*queue.back() = merged
+					return
+				}
+			}
+			queue.push(x)
+		}
 
 		for {
 			x, ok := <-in
@@ -46,21 +128,22 @@ func MakeEventsChan() (<-chan Event, chan<- Event) {
 				close(out)
 				return
 			}
-			queue = append(queue, x)
+			push(x)
 
-			for len(queue) > 0 {
+			for queue.len() > 0 {
 				select {
-				case out <- queue[0]:
-					queue = queue[1:]
+				case out <- queue.front():
+					queue.pop()
 				case x, ok := <-in:
 					if !ok {
-						for _, x := range queue {
-							out <- x
+						for queue.len() > 0 {
+							out <- queue.front()
+							queue.pop()
 						}
 						close(out)
 						return
 					}
-					queue = append(queue, x)
+					push(x)
 				}
 			}
 		}
@@ -0,0 +1,12 @@
+package gui
+
+// RegisteredPrefixes returns the prefixes registered using RegisterEvent.
+func RegisteredPrefixes() []string {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	var prefixes []string
+	for prefix := range parsers {
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
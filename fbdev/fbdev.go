@@ -0,0 +1,201 @@
+// Package fbdev implements a gui.Env drawing to a framebuffer, such as the Linux framebuffer
+// device /dev/fb0. It needs no windowing system, which makes it suitable for embedded devices
+// and kiosks.
+//
+// A framebuffer produces no input events on its own. Use the Input option to forward them from
+// elsewhere, for example from package evdev.
+package fbdev
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/internal/damage"
+)
+
+// Option is a functional option to New and Open.
+type Option func(*options)
+
+type options struct {
+	input    <-chan gui.Event
+	coalesce bool
+	recovery gui.Recovery
+}
+
+// Input option makes the Env produce the events received from the channel, after its initial
+// Resize event. Closing the channel stops the forwarding, but doesn't close the Env.
+func Input(events <-chan gui.Event) Option {
+	return func(o *options) {
+		o.input = events
+	}
+}
+
+// CoalesceEvents option makes the Env merge the consecutive mouse moves and scrolls forwarded by
+// the Input option which haven't been received yet, such as the moves of a finger on a touch
+// screen. See gui.MakeCoalescingEventsChan.
+func CoalesceEvents() Option {
+	return func(o *options) {
+		o.coalesce = true
+	}
+}
+
+// RecoverPanics option makes the Env recover the panics in the draw functions sent to it and
+// report them to errs, see gui.Recovery. On a device with no other way to show an error, it
+// keeps the screen alive.
+func RecoverPanics(errs chan<- error) Option {
+	return func(o *options) {
+		o.recovery = gui.Recovery{Recover: true, Errs: errs}
+	}
+}
+
+// Buffer makes a byte slice, such as the memory-mapped memory of a framebuffer device, usable
+// as the destination of New.
+func Buffer(b []byte) io.WriterAt {
+	return buffer(b)
+}
+
+type buffer []byte
+
+func (b buffer) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off > int64(len(b)) {
+		return 0, fmt.Errorf("offset %d out of range", off)
+	}
+	n := copy(b[off:], p)
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// Env is a gui.Env drawing to a framebuffer.
+//
+// It draws to an *image.RGBA and writes the changed parts to the framebuffer, converted to its
+// pixel format. If writing fails, the Env closes its Events() channel.
+type Env struct {
+	eventsOut <-chan gui.Event
+	eventsIn  chan<- gui.Event
+	draw      chan func(draw.Image) image.Rectangle
+	damage    *damage.Damage
+	stop      chan struct{}
+
+	recovery gui.Recovery
+
+	dst    io.WriterAt
+	format Format
+	closer io.Closer
+	img    *image.RGBA
+}
+
+// New creates an Env drawing to dst, which can be a file or a Buffer, whose memory is described
+// by the format. The Env reports the size of the framebuffer in its initial Resize event and
+// doesn't produce any other events, unless the Input option is used.
+func New(dst io.WriterAt, format Format, opts ...Option) (*Env, error) {
+	return newEnv(dst, format, nil, opts)
+}
+
+func newEnv(dst io.WriterAt, format Format, closer io.Closer, opts []Option) (*Env, error) {
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	eventsOut, eventsIn := gui.MakeEventsChan()
+	if o.coalesce {
+		eventsOut, eventsIn = gui.MakeCoalescingEventsChan()
+	}
+
+	env := &Env{
+		eventsOut: eventsOut,
+		eventsIn:  eventsIn,
+		draw:      make(chan func(draw.Image) image.Rectangle),
+		damage:    damage.New(),
+		stop:      make(chan struct{}),
+		recovery:  o.recovery,
+		dst:       dst,
+		format:    format,
+		closer:    closer,
+		img:       image.NewRGBA(image.Rect(0, 0, format.Width, format.Height)),
+	}
+
+	env.eventsIn <- gui.Resize{Rectangle: env.img.Bounds()}
+
+	go env.forward(o.input)
+	go env.drawLoop()
+
+	return env, nil
+}
+
+// Events returns the events channel of the Env.
+func (env *Env) Events() <-chan gui.Event { return env.eventsOut }
+
+// Draw returns the draw channel of the Env.
+func (env *Env) Draw() chan<- func(draw.Image) image.Rectangle { return env.draw }
+
+// Frames returns a channel that receives the time of each write of the changes to the
+// framebuffer. The Env does not block sending to it, so the frames nobody receives get dropped.
+// See anim.Framer.
+func (env *Env) Frames() <-chan time.Time { return env.damage.Frames() }
+
+// Format returns the format of the framebuffer.
+func (env *Env) Format() Format { return env.format }
+
+func (env *Env) forward(input <-chan gui.Event) {
+	defer close(env.eventsIn)
+	for {
+		select {
+		case e, ok := <-input:
+			if !ok {
+				input = nil
+				continue
+			}
+			env.eventsIn <- e
+		case <-env.stop:
+			return
+		}
+	}
+}
+
+func (env *Env) drawLoop() {
+	var (
+		buf     []byte
+		stopped bool
+	)
+	stop := func() {
+		if !stopped {
+			stopped = true
+			close(env.stop)
+		}
+	}
+
+	for {
+		select {
+		case d, ok := <-env.draw:
+			if !ok {
+				stop()
+				if env.closer != nil {
+					env.closer.Close()
+				}
+				return
+			}
+			env.damage.Add(env.recovery.Draw(env, d, env.img))
+
+		case <-env.damage.Ready():
+			if r := env.damage.Take(env.img.Bounds()); !r.Empty() && !stopped {
+				var err error
+				buf, err = env.format.blit(env.dst, env.img, r, buf)
+				if err != nil {
+					stop() // keep receiving the draw functions until the Draw() channel closes
+				} else {
+					env.damage.Flushed()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package fbdev_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui/fbdev"
+)
+
+// pixel returns the bytes of the color in the pixel format, written out by hand.
+func pixel(pf fbdev.PixelFormat, c color.RGBA) []byte {
+	switch pf {
+	case fbdev.RGB565:
+		p := uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+		return []byte{byte(p), byte(p >> 8)}
+	case fbdev.XRGB8888:
+		return []byte{c.B, c.G, c.R, 0}
+	case fbdev.XBGR8888:
+		return []byte{c.R, c.G, c.B, 0}
+	case fbdev.RGB888:
+		return []byte{c.B, c.G, c.R}
+	case fbdev.BGR888:
+		return []byte{c.R, c.G, c.B}
+	}
+	panic(fmt.Sprintf("unknown pixel format %v", pf))
+}
+
+func TestBlit(t *testing.T) {
+	const unwritten = 0xee
+	fills := []struct {
+		r image.Rectangle
+		c color.RGBA
+	}{
+		{image.Rect(1, 1, 3, 3), color.RGBA{0x12, 0x34, 0x56, 0xff}},
+		{image.Rect(3, 2, 9, 9), color.RGBA{0xff, 0x80, 0x01, 0xff}}, // clipped at the edges
+	}
+
+	for _, pf := range []fbdev.PixelFormat{fbdev.RGB565, fbdev.XRGB8888, fbdev.XBGR8888, fbdev.RGB888, fbdev.BGR888} {
+		bpp := pf.BytesPerPixel()
+		format := fbdev.Format{Width: 5, Height: 4, Stride: 5*bpp + 3, Pixel: pf} // padded rows
+		b := bytes.Repeat([]byte{unwritten}, format.Stride*format.Height)
+		env, err := fbdev.New(fbdev.Buffer(b), format)
+		if err != nil {
+			t.Fatalf("%v: %v", pf, err)
+		}
+		if e := <-env.Events(); e.String() != "resize/0/0/5/4" {
+			t.Fatalf("%v: got %v, want the initial Resize", pf, e)
+		}
+
+		for _, fill := range fills {
+			fill := fill
+			env.Draw() <- func(drw draw.Image) image.Rectangle {
+				draw.Draw(drw, fill.r, &image.Uniform{fill.c}, image.ZP, draw.Src)
+				return fill.r
+			}
+			select {
+			case <-env.Frames():
+			case <-time.After(time.Second):
+				t.Fatalf("%v: nothing written within 1s", pf)
+			}
+		}
+		close(env.Draw())
+		for range env.Events() {
+		}
+
+		want := bytes.Repeat([]byte{unwritten}, len(b))
+		for _, fill := range fills {
+			r := fill.r.Intersect(image.Rect(0, 0, format.Width, format.Height))
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					copy(want[y*format.Stride+x*bpp:], pixel(pf, fill.c))
+				}
+			}
+		}
+		for y := 0; y < format.Height; y++ {
+			row := b[y*format.Stride : (y+1)*format.Stride]
+			wantRow := want[y*format.Stride : (y+1)*format.Stride]
+			if !bytes.Equal(row, wantRow) {
+				t.Errorf("%v: row %d is\n% x\nwant\n% x", pf, y, row, wantRow)
+			}
+		}
+	}
+}
+
+func TestFormatValidation(t *testing.T) {
+	for _, format := range []fbdev.Format{
+		{Width: 0, Height: 4, Stride: 20, Pixel: fbdev.XRGB8888},
+		{Width: 5, Height: 4, Stride: 19, Pixel: fbdev.XRGB8888},
+		{Width: 5, Height: 4, Stride: 14, Pixel: fbdev.RGB888},
+		{Width: 5, Height: 4, Stride: 20, Pixel: fbdev.BGR888 + 1},
+	} {
+		if _, err := fbdev.New(fbdev.Buffer(make([]byte, 100)), format); err == nil {
+			t.Errorf("New accepted the invalid format %+v", format)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package fbdev
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+// PixelFormat is the layout of a pixel in the memory of a framebuffer.
+type PixelFormat int
+
+// List of the supported pixel formats. The names list the components from the most significant
+// bits to the least significant ones, the pixels are stored in the little-endian byte order.
+const (
+	RGB565   PixelFormat = iota // 16 bits, 5 bits red, 6 bits green, 5 bits blue
+	XRGB8888                    // 32 bits, bytes in memory are blue, green, red, unused
+	XBGR8888                    // 32 bits, bytes in memory are red, green, blue, unused
+	RGB888                      // 24 bits, bytes in memory are blue, green, red
+	BGR888                      // 24 bits, bytes in memory are red, green, blue
+)
+
+// BytesPerPixel returns the size of a pixel in bytes.
+func (pf PixelFormat) BytesPerPixel() int {
+	switch pf {
+	case RGB565:
+		return 2
+	case RGB888, BGR888:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func (pf PixelFormat) String() string {
+	switch pf {
+	case RGB565:
+		return "RGB565"
+	case XRGB8888:
+		return "XRGB8888"
+	case XBGR8888:
+		return "XBGR8888"
+	case RGB888:
+		return "RGB888"
+	case BGR888:
+		return "BGR888"
+	}
+	return fmt.Sprintf("PixelFormat(%d)", int(pf))
+}
+
+// Format describes the memory of a framebuffer: its size in pixels, the number of bytes between
+// the starts of two consecutive rows and the format of the pixels.
+type Format struct {
+	Width, Height int
+	Stride        int
+	Pixel         PixelFormat
+}
+
+func (f Format) validate() error {
+	if f.Width <= 0 || f.Height <= 0 {
+		return fmt.Errorf("fbdev: invalid size %dx%d", f.Width, f.Height)
+	}
+	if f.Pixel < RGB565 || f.Pixel > BGR888 {
+		return fmt.Errorf("fbdev: unsupported pixel format %v", f.Pixel)
+	}
+	if f.Stride < f.Width*f.Pixel.BytesPerPixel() {
+		return fmt.Errorf("fbdev: stride %d too small for %d pixels of %v", f.Stride, f.Width, f.Pixel)
+	}
+	return nil
+}
+
+// convert converts the row of RGBA pixels to the pixel format, appending to dst.
+func (pf PixelFormat) convert(dst, rgba []byte) []byte {
+	for i := 0; i+4 <= len(rgba); i += 4 {
+		r, g, b := rgba[i], rgba[i+1], rgba[i+2]
+		switch pf {
+		case RGB565:
+			p := uint16(r>>3)<<11 | uint16(g>>2)<<5 | uint16(b>>3)
+			dst = append(dst, byte(p), byte(p>>8))
+		case XRGB8888:
+			dst = append(dst, b, g, r, 0)
+		case XBGR8888:
+			dst = append(dst, r, g, b, 0)
+		case RGB888:
+			dst = append(dst, b, g, r)
+		case BGR888:
+			dst = append(dst, r, g, b)
+		}
+	}
+	return dst
+}
+
+// blit converts the rectangle r of img to the format and writes it to dst row by row.
+func (f Format) blit(dst io.WriterAt, img *image.RGBA, r image.Rectangle, buf []byte) ([]byte, error) {
+	bpp := f.Pixel.BytesPerPixel()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		buf = f.Pixel.convert(buf[:0], img.Pix[i:i+4*r.Dx()])
+		if _, err := dst.WriteAt(buf, int64(y*f.Stride+r.Min.X*bpp)); err != nil {
+			return buf, fmt.Errorf("fbdev: %v", err)
+		}
+	}
+	return buf, nil
+}
@@ -0,0 +1,149 @@
+//go:build linux
+// +build linux
+
+package fbdev
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctls from linux/fb.h
+const (
+	fbioGetVScreenInfo = 0x4600
+	fbioGetFScreenInfo = 0x4602
+)
+
+type bitfield struct {
+	Offset, Length, MSBRight uint32
+}
+
+// varScreenInfo is struct fb_var_screeninfo.
+type varScreenInfo struct {
+	XRes, YRes               uint32
+	XResVirtual, YResVirtual uint32
+	XOffset, YOffset         uint32
+	BitsPerPixel             uint32
+	Grayscale                uint32
+	Red, Green, Blue, Transp bitfield
+	NonStd, Activate         uint32
+	Height, Width            uint32
+	AccelFlags, PixClock     uint32
+	LeftMargin, RightMargin  uint32
+	UpperMargin, LowerMargin uint32
+	HSyncLen, VSyncLen       uint32
+	Sync, VMode              uint32
+	Rotate, Colorspace       uint32
+	Reserved                 [4]uint32
+}
+
+// fixScreenInfo is struct fb_fix_screeninfo. The unsigned longs are uintptrs, so that the
+// layout matches on both 32-bit and 64-bit platforms.
+type fixScreenInfo struct {
+	ID                            [16]byte
+	SmemStart                     uintptr
+	SmemLen                       uint32
+	Type, TypeAux, Visual         uint32
+	XPanStep, YPanStep, YWrapStep uint16
+	LineLength                    uint32
+	MmioStart                     uintptr
+	MmioLen                       uint32
+	Accel                         uint32
+	Capabilities                  uint16
+	Reserved                      [2]uint16
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Open opens a framebuffer device, such as "/dev/fb0", queries its geometry and pixel format,
+// maps its memory and creates an Env drawing to it. Closing the Draw() channel of the Env
+// unmaps the memory and closes the device.
+func Open(path string, opts ...Option) (*Env, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("fbdev: %v", err)
+	}
+
+	var vinfo varScreenInfo
+	var finfo fixScreenInfo
+	if err := ioctl(f.Fd(), fbioGetVScreenInfo, unsafe.Pointer(&vinfo)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("fbdev: %s: FBIOGET_VSCREENINFO: %v", path, err)
+	}
+	if err := ioctl(f.Fd(), fbioGetFScreenInfo, unsafe.Pointer(&finfo)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("fbdev: %s: FBIOGET_FSCREENINFO: %v", path, err)
+	}
+
+	pixel, err := pixelFormat(&vinfo)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("fbdev: %s: %v", path, err)
+	}
+	format := Format{
+		Width:  int(vinfo.XRes),
+		Height: int(vinfo.YRes),
+		Stride: int(finfo.LineLength),
+		Pixel:  pixel,
+	}
+
+	mem, err := syscall.Mmap(int(f.Fd()), 0, int(finfo.SmemLen), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("fbdev: %s: mmap: %v", path, err)
+	}
+
+	// the visible part of the framebuffer may be panned
+	offset := int(vinfo.YOffset)*format.Stride + int(vinfo.XOffset)*pixel.BytesPerPixel()
+	if offset+format.Height*format.Stride > len(mem) {
+		syscall.Munmap(mem)
+		f.Close()
+		return nil, fmt.Errorf("fbdev: %s: visible area outside of the memory", path)
+	}
+
+	env, err := newEnv(Buffer(mem[offset:]), format, &device{f, mem}, opts)
+	if err != nil {
+		syscall.Munmap(mem)
+		f.Close()
+		return nil, err
+	}
+	return env, nil
+}
+
+// pixelFormat recognizes the pixel format from the bit depth and the position of red.
+func pixelFormat(vinfo *varScreenInfo) (PixelFormat, error) {
+	switch {
+	case vinfo.BitsPerPixel == 16 && vinfo.Red.Offset == 11:
+		return RGB565, nil
+	case vinfo.BitsPerPixel == 32 && vinfo.Red.Offset == 16:
+		return XRGB8888, nil
+	case vinfo.BitsPerPixel == 32 && vinfo.Red.Offset == 0:
+		return XBGR8888, nil
+	case vinfo.BitsPerPixel == 24 && vinfo.Red.Offset == 16:
+		return RGB888, nil
+	case vinfo.BitsPerPixel == 24 && vinfo.Red.Offset == 0:
+		return BGR888, nil
+	}
+	return 0, fmt.Errorf("unsupported pixel format: %d bits per pixel, red at bit %d",
+		vinfo.BitsPerPixel, vinfo.Red.Offset)
+}
+
+type device struct {
+	f   *os.File
+	mem []byte
+}
+
+func (d *device) Close() error {
+	err := syscall.Munmap(d.mem)
+	if cerr := d.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package fbdev
+
+import "errors"
+
+// Open is only supported on Linux. Elsewhere, use New with a file or a Buffer.
+func Open(path string, opts ...Option) (*Env, error) {
+	return nil, errors.New("fbdev: framebuffer devices are only supported on Linux")
+}
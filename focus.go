@@ -0,0 +1,145 @@
+package gui
+
+import "fmt"
+
+// FocusGain is an event that happens when an Env created by a Mux gains the keyboard focus.
+type FocusGain struct{}
+
+// FocusLose is an event that happens when an Env created by a Mux loses the keyboard focus.
+type FocusLose struct{}
+
+func (FocusGain) String() string { return "focus/gain" }
+func (FocusLose) String() string { return "focus/lose" }
+
+func init() {
+	RegisterEvent("focus/gain", parseFocus)
+	RegisterEvent("focus/lose", parseFocus)
+}
+
+func parseFocus(s string) (Event, error) {
+	switch s {
+	case "focus/gain":
+		return FocusGain{}, nil
+	case "focus/lose":
+		return FocusLose{}, nil
+	}
+	return nil, fmt.Errorf("gui: invalid event %q", s)
+}
+
+// Focus gives the keyboard focus to the Env, which must have been created by the Mux. The Env
+// that had the focus before receives FocusLose and the Env gets FocusGain. Passing nil removes
+// the focus from all Envs.
+//
+// Keyboard events, those implementing KeyboardEvent, are only sent to the Env that has the
+// focus. When no Env has the focus, they are sent to all Envs.
+//
+// If any Env was created with the Focusable option, the Mux handles the Tab key itself: it
+// moves the focus to the next Focusable Env in the order of creation, or to the previous one
+// when Shift is held down. The key events of the Tab key are then not sent to any Env.
+//
+// When the Env that has the focus closes, the focus moves to the next Focusable Env, if the
+// closed Env was Focusable itself. Otherwise, no Env has the focus afterwards.
+func (mux *Mux) Focus(env Env) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if env == nil {
+		mux.setFocus(nil)
+		return
+	}
+	env = unwrapEnv(env)
+	for _, m := range mux.envs {
+		if m == env {
+			mux.setFocus(m)
+			return
+		}
+	}
+}
+
+// setFocus must be called with mux.mu locked.
+func (mux *Mux) setFocus(m *muxEnv) {
+	if m == mux.focus {
+		return
+	}
+	if mux.focus != nil {
+		mux.post(mux.focus, FocusLose{})
+	}
+	mux.focus = m
+	if mux.focus != nil {
+		mux.post(mux.focus, FocusGain{})
+	}
+}
+
+// nextFocusable returns the Focusable Env following the Env m in the order of creation, or
+// preceding it if not forward, wrapping around. If m is not Focusable, it returns the first (or
+// the last) Focusable Env. It returns nil if there is no Focusable Env. It must be called with
+// mux.mu locked.
+func (mux *Mux) nextFocusable(m *muxEnv, forward bool) *muxEnv {
+	var focusable []*muxEnv
+	current := -1
+	for _, f := range mux.envs {
+		if !f.opts.focusable {
+			continue
+		}
+		if f == m {
+			current = len(focusable)
+		}
+		focusable = append(focusable, f)
+	}
+	if len(focusable) == 0 {
+		return nil
+	}
+
+	var next int
+	switch {
+	case current == -1 && forward:
+		next = 0
+	case current == -1:
+		next = len(focusable) - 1
+	case forward:
+		next = (current + 1) % len(focusable)
+	default:
+		next = (current - 1 + len(focusable)) % len(focusable)
+	}
+	return focusable[next]
+}
+
+// moveFocus moves the focus to the next (or previous) Focusable Env. It reports whether there
+// is any Focusable Env. It must be called with mux.mu locked.
+func (mux *Mux) moveFocus(forward bool) bool {
+	next := mux.nextFocusable(mux.focus, forward)
+	if next == nil {
+		return false
+	}
+	mux.setFocus(next)
+	return true
+}
+
+// dispatchKeyboard handles the focus and the sending of keyboard events. It reports whether
+// the event was a keyboard event and got handled. It must be called with mux.mu locked.
+func (mux *Mux) dispatchKeyboard(e Event) bool {
+	ke, ok := e.(KeyboardEvent)
+	if !ok {
+		return false
+	}
+
+	switch key, down := ke.KeyState(); {
+	case key == "shift":
+		mux.shift = down
+	case key == "tab" && down:
+		if mux.moveFocus(!mux.shift) {
+			return true
+		}
+	case key == "tab":
+		for _, m := range mux.envs {
+			if m.opts.focusable {
+				return true
+			}
+		}
+	}
+
+	if mux.focus == nil {
+		return false
+	}
+	mux.post(mux.focus, e)
+	return true
+}
@@ -0,0 +1,109 @@
+package gui_test
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/win"
+)
+
+// keyboard returns the focus and keyboard events of the Env up to and including the one whose
+// String is last.
+func keyboard(t *testing.T, env gui.Env, last string) []string {
+	t.Helper()
+	var got []string
+	for _, e := range eventsUntil(t, env, last) {
+		if strings.HasPrefix(e, "focus/") || strings.HasPrefix(e, "kb/") {
+			got = append(got, e)
+		}
+	}
+	return got
+}
+
+func checkEvents(t *testing.T, name string, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("%s got %q, want %q", name, got, want)
+	}
+}
+
+func TestFocus(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	a, b := mux.MakeEnv(), mux.MakeEnv()
+
+	mux.Focus(a)
+	root.Send(win.KbType{Rune: 'x'})
+	checkEvents(t, "a", keyboard(t, a, "kb/type/120"), "focus/gain", "kb/type/120")
+
+	mux.Focus(b)
+	root.Send(win.KbDown{Key: win.KeyEnter})
+	checkEvents(t, "b", keyboard(t, b, "kb/down/enter"), "focus/gain", "kb/down/enter")
+
+	// nothing has the focus, so the keyboard events go to all the Envs
+	mux.Focus(nil)
+	root.Send(win.KbType{Rune: 'y'})
+	checkEvents(t, "a", keyboard(t, a, "kb/type/121"), "focus/lose", "kb/type/121")
+	checkEvents(t, "b", keyboard(t, b, "kb/type/121"), "focus/lose", "kb/type/121")
+}
+
+func TestFocusTab(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	a := mux.MakeEnv(gui.Focusable())
+	plain := mux.MakeEnv()
+	b := mux.MakeEnv(gui.Focusable())
+
+	tab := func() {
+		root.Send(win.KbDown{Key: win.KeyTab})
+		root.Send(win.KbRepeat{Key: win.KeyTab})
+		root.Send(win.KbUp{Key: win.KeyTab})
+	}
+	root.Send(win.KbDown{Key: win.KeyTab}) // a
+	root.Send(win.KbUp{Key: win.KeyTab})
+	tab() // b, a
+	root.Send(win.KbDown{Key: win.KeyShift})
+	root.Send(win.KbDown{Key: win.KeyTab}) // b
+	root.Send(win.KbUp{Key: win.KeyTab})
+	root.Send(win.KbUp{Key: win.KeyShift})
+	root.Send(win.MoMove{Point: image.Pt(1, 1)})
+
+	checkEvents(t, "a", keyboard(t, a, "mo/move/1/1"),
+		"focus/gain", "focus/lose", "focus/gain", "kb/down/shift", "focus/lose")
+	checkEvents(t, "b", keyboard(t, b, "mo/move/1/1"),
+		"focus/gain", "focus/lose", "focus/gain", "kb/up/shift")
+	checkEvents(t, "the Env which is not Focusable", keyboard(t, plain, "mo/move/1/1"))
+}
+
+func TestFocusTabWithoutFocusable(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	a, b := mux.MakeEnv(), mux.MakeEnv()
+
+	// no Env is Focusable, so the Tab key is just a key
+	root.Send(win.KbDown{Key: win.KeyTab})
+	root.Send(win.KbUp{Key: win.KeyTab})
+	checkEvents(t, "a", keyboard(t, a, "kb/up/tab"), "kb/down/tab", "kb/up/tab")
+	checkEvents(t, "b", keyboard(t, b, "kb/up/tab"), "kb/down/tab", "kb/up/tab")
+}
+
+func TestFocusMovesFromClosedEnv(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	a := mux.MakeChild(gui.Focusable())
+	b := mux.MakeChild(gui.Focusable())
+	c := mux.MakeChild(gui.Focusable())
+	plain := mux.MakeChild()
+
+	mux.Focus(b)
+	b.Close()
+	checkEvents(t, "b", keyboard(t, b, "focus/gain"), "focus/gain")
+	c.Close() // the focus wraps around to a
+	root.Send(win.KbType{Rune: 'x'})
+	checkEvents(t, "a", keyboard(t, a, "kb/type/120"), "focus/gain", "kb/type/120")
+	checkEvents(t, "c", keyboard(t, c, "focus/gain"), "focus/gain")
+
+	// the Env which is not Focusable doesn't pass the focus on
+	mux.Focus(plain)
+	plain.Close()
+	root.Send(win.KbType{Rune: 'y'})
+	checkEvents(t, "a", keyboard(t, a, "kb/type/121"), "focus/lose", "kb/type/121")
+}
@@ -0,0 +1,243 @@
+// Package guitest implements golden-image testing of components.
+//
+// A component is a function taking an Env, such as a button or a text field. Snapshot runs it on
+// a headless Env, feeds it events, waits until it stops drawing and compares the result with a
+// PNG file:
+//
+//	func TestButton(t *testing.T) {
+//		guitest.Snapshot(t, "testdata/button_pressed.png", image.Rect(0, 0, 100, 30),
+//			func(env gui.Env) { Button(env, theme, "OK", func() {}) },
+//			[]gui.Event{win.MoDown{Point: image.Pt(50, 15), Button: win.ButtonLeft}},
+//		)
+//	}
+//
+// Running the tests with the -guitest.update flag writes the golden files instead of comparing
+// them. The flag is namespaced so that it doesn't clash with an -update flag of the tests.
+package guitest
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/headless"
+)
+
+var update = flag.Bool("guitest.update", false, "update the golden images of guitest")
+
+// Option is a functional option to Run, Golden and Snapshot.
+type Option func(*options)
+
+type options struct {
+	tolerance uint8
+	quiet     time.Duration
+	timeout   time.Duration
+}
+
+// Tolerance option sets the maximum difference of each color channel (in the 0-255 range)
+// between a pixel and its golden counterpart for the pixels to be considered equal.
+func Tolerance(tolerance uint8) Option {
+	return func(o *options) {
+		o.tolerance = tolerance
+	}
+}
+
+// Settle option sets for how long a component must not draw anything, after drawing at least
+// once, to be considered done with drawing.
+func Settle(quiet time.Duration) Option {
+	return func(o *options) {
+		o.quiet = quiet
+	}
+}
+
+// Timeout option sets how long to wait for a component to settle and to close.
+func Timeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+func makeOptions(opts []Option) options {
+	o := options{
+		tolerance: 0,
+		quiet:     50 * time.Millisecond,
+		timeout:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Run runs the component on a headless Env with the drawing area covering bounds, sends it the
+// events in order and returns the content of the drawing area once the component settles. The
+// component settles once it has drawn at least once and then stopped drawing, see Settle.
+// Afterwards, it closes the Env and checks that the component closes its Draw() channel.
+func Run(t testing.TB, bounds image.Rectangle, component func(gui.Env), events []gui.Event, opts ...Option) *image.RGBA {
+	t.Helper()
+	o := makeOptions(opts)
+
+	env := headless.New(bounds)
+	done := make(chan struct{})
+	go func() {
+		component(env)
+		close(done)
+	}()
+
+	for _, e := range events {
+		env.Send(e)
+	}
+	// a slow component must not be taken as settled before it starts drawing
+	deadline := time.Now().Add(o.timeout)
+	for env.Draws() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if env.Draws() == 0 {
+		t.Errorf("component did not draw anything within %v", o.timeout)
+	} else if !env.WaitIdle(o.quiet, time.Until(deadline)) {
+		t.Errorf("component did not stop drawing within %v", o.timeout)
+	}
+	img := env.Image()
+
+	env.Close()
+	select {
+	case <-done:
+	case <-time.After(o.timeout):
+		t.Errorf("component did not return within %v after closing its Env", o.timeout)
+	}
+
+	return img
+}
+
+// Golden compares img with the PNG image stored in the golden file. If they differ, it fails
+// the test and writes the actual image and an image highlighting the differences next to the
+// golden file, with the ".actual.png" and ".diff.png" suffixes.
+//
+// If the -guitest.update flag is set, Golden writes img to the golden file instead.
+func Golden(t testing.TB, img image.Image, golden string, opts ...Option) {
+	t.Helper()
+	o := makeOptions(opts)
+
+	base := strings.TrimSuffix(golden, filepath.Ext(golden))
+	actualPath, diffPath := base+".actual.png", base+".diff.png"
+
+	if *update {
+		if err := writePNG(golden, img); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(actualPath)
+		os.Remove(diffPath)
+		return
+	}
+
+	want, err := readPNG(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -guitest.update to create it)", err)
+	}
+	// PNG images always start at (0, 0), while the drawing area of a component may not
+	want = moveImage(want, img.Bounds().Min)
+
+	diff, n := compare(img, want, o.tolerance)
+	if n == 0 {
+		os.Remove(actualPath)
+		os.Remove(diffPath)
+		return
+	}
+
+	if err := writePNG(actualPath, img); err != nil {
+		t.Error(err)
+	}
+	if err := writePNG(diffPath, diff); err != nil {
+		t.Error(err)
+	}
+	if img.Bounds() != want.Bounds() {
+		t.Errorf("image bounds %v differ from golden %v bounds %v, see %s", img.Bounds(), golden, want.Bounds(), actualPath)
+		return
+	}
+	t.Errorf("%d pixels differ from golden %v, see %s and %s", n, golden, actualPath, diffPath)
+}
+
+// Snapshot combines Run and Golden: it runs the component and compares the result with the
+// golden file.
+func Snapshot(t testing.TB, golden string, bounds image.Rectangle, component func(gui.Env), events []gui.Event, opts ...Option) {
+	t.Helper()
+	img := Run(t, bounds, component, events, opts...)
+	Golden(t, img, golden, opts...)
+}
+
+// compare returns an image highlighting the differing pixels of the two images in red over a
+// faded copy of the actual image, along with the number of differing pixels.
+func compare(actual, want image.Image, tolerance uint8) (diff *image.RGBA, n int) {
+	r := actual.Bounds().Union(want.Bounds())
+	diff = image.NewRGBA(r)
+	draw.Draw(diff, r, image.White, image.ZP, draw.Src)
+	draw.DrawMask(diff, actual.Bounds(), actual, actual.Bounds().Min, &image.Uniform{color.Alpha{64}}, image.ZP, draw.Over)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := image.Pt(x, y)
+			if p.In(actual.Bounds()) && p.In(want.Bounds()) && equalColors(actual.At(x, y), want.At(x, y), tolerance) {
+				continue
+			}
+			diff.Set(x, y, color.RGBA{255, 0, 0, 255})
+			n++
+		}
+	}
+
+	return diff, n
+}
+
+func moveImage(img image.Image, min image.Point) image.Image {
+	moved := image.NewRGBA(img.Bounds().Sub(img.Bounds().Min).Add(min))
+	draw.Draw(moved, moved.Bounds(), img, img.Bounds().Min, draw.Src)
+	return moved
+}
+
+func equalColors(c1, c2 color.Color, tolerance uint8) bool {
+	n1 := color.NRGBAModel.Convert(c1).(color.NRGBA)
+	n2 := color.NRGBAModel.Convert(c2).(color.NRGBA)
+	within := func(a, b uint8) bool {
+		if a > b {
+			a, b = b, a
+		}
+		return b-a <= tolerance
+	}
+	return within(n1.R, n2.R) && within(n1.G, n2.G) && within(n1.B, n2.B) && within(n1.A, n2.A)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,107 @@
+package guitest
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+// square fills the drawing area, minus a 2 pixel border, with clr after waiting for delay.
+func square(clr color.Color, delay time.Duration) func(gui.Env) {
+	return func(env gui.Env) {
+		for e := range env.Events() {
+			if r, ok := e.(gui.Resize); ok {
+				time.Sleep(delay)
+				env.Draw() <- func(drw draw.Image) image.Rectangle {
+					draw.Draw(drw, r.Rectangle.Inset(2), &image.Uniform{clr}, image.ZP, draw.Src)
+					return r.Rectangle
+				}
+			}
+		}
+		close(env.Draw())
+	}
+}
+
+// recorder records the failures of a test instead of failing it.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Error(args ...interface{}) { r.errors = append(r.errors, fmt.Sprint(args...)) }
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestSnapshot(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	Snapshot(t, "testdata/square.png", image.Rect(5, 5, 21, 21), square(red, 0), nil)
+}
+
+func TestSnapshotTolerance(t *testing.T) {
+	Snapshot(t, "testdata/square.png", image.Rect(5, 5, 21, 21), square(color.RGBA{250, 0, 0, 255}, 0), nil, Tolerance(5))
+}
+
+func TestRunWaitsForFirstDraw(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	img := Run(t, image.Rect(0, 0, 16, 16), square(red, 100*time.Millisecond), nil, Settle(10*time.Millisecond))
+	if got := img.At(8, 8); got != red {
+		t.Errorf("got %v at the center, want %v", got, red)
+	}
+
+	rec := &recorder{TB: t}
+	Run(rec, image.Rect(0, 0, 16, 16), func(env gui.Env) {
+		for range env.Events() {
+		}
+		close(env.Draw())
+	}, nil, Timeout(100*time.Millisecond))
+	if len(rec.errors) != 1 {
+		t.Errorf("got errors %q for a component which never draws, want one", rec.errors)
+	}
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	want, err := readPNG("testdata/square.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join(t.TempDir(), "square.png")
+	if err := writePNG(golden, want); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{TB: t}
+	blue := color.RGBA{0, 0, 255, 255}
+	Snapshot(rec, golden, image.Rect(5, 5, 21, 21), square(blue, 0), nil)
+	if len(rec.errors) != 1 {
+		t.Fatalf("got errors %q, want one", rec.errors)
+	}
+	for _, suffix := range []string{".actual.png", ".diff.png"} {
+		path := filepath.Join(filepath.Dir(golden), "square"+suffix)
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("mismatch did not write %s: %v", path, err)
+		}
+	}
+
+	img := Run(t, image.Rect(5, 5, 21, 21), square(blue, 0), nil)
+	if _, n := compare(img, moveImage(want, img.Bounds().Min), 0); n != 12*12 {
+		t.Errorf("got %d differing pixels, want %d", n, 12*12)
+	}
+}
+
+func TestUpdateFlag(t *testing.T) {
+	if flag.Lookup("guitest.update") == nil {
+		t.Error("-guitest.update flag is not registered")
+	}
+	if flag.Lookup("update") != nil {
+		t.Error("guitest registers the -update flag, which belongs to the tests")
+	}
+}
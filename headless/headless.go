@@ -0,0 +1,159 @@
+// Package headless implements an Env that draws into an in-memory image instead of a window.
+//
+// It is useful for testing components without a display: events can be injected with Send,
+// the drawing area can be resized with Resize and the result can be inspected with Image.
+package headless
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+// Env is an Env whose drawing area is an in-memory *image.RGBA.
+//
+// Just like any other Env, it produces a gui.Resize as its first event. Closing its Draw()
+// channel closes its Events() channel.
+type Env struct {
+	eventsOut <-chan gui.Event
+	eventsIn  chan<- gui.Event
+	draw      chan func(draw.Image) image.Rectangle
+
+	mu     sync.Mutex
+	closed bool
+	img    *image.RGBA
+	damage image.Rectangle
+	draws  int
+}
+
+// New creates a new headless Env with a drawing area covering the rectangle r.
+func New(r image.Rectangle) *Env {
+	eventsOut, eventsIn := gui.MakeEventsChan()
+
+	env := &Env{
+		eventsOut: eventsOut,
+		eventsIn:  eventsIn,
+		draw:      make(chan func(draw.Image) image.Rectangle),
+		img:       image.NewRGBA(r),
+	}
+
+	eventsIn <- gui.Resize{Rectangle: r}
+
+	go env.loop()
+
+	return env
+}
+
+// Events returns the events channel of the Env.
+func (env *Env) Events() <-chan gui.Event { return env.eventsOut }
+
+// Draw returns the draw channel of the Env.
+func (env *Env) Draw() chan<- func(draw.Image) image.Rectangle { return env.draw }
+
+func (env *Env) loop() {
+	for d := range env.draw {
+		env.mu.Lock()
+		r := d(env.img)
+		env.damage = env.damage.Union(r)
+		env.draws++
+		env.mu.Unlock()
+	}
+
+	env.Close()
+}
+
+// Close closes the Events() channel of the Env, just like closing a window would. The user of
+// the Env should subsequently close the Draw() channel. Calling Close more than once is fine.
+func (env *Env) Close() {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.closed {
+		return
+	}
+	env.closed = true
+	close(env.eventsIn)
+}
+
+// WaitIdle blocks until no draw function gets executed for the duration quiet, or until timeout
+// passes. It reports whether the Env became idle.
+func (env *Env) WaitIdle(quiet, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		env.mu.Lock()
+		before := env.draws
+		env.mu.Unlock()
+
+		time.Sleep(quiet)
+
+		env.mu.Lock()
+		after := env.draws
+		env.mu.Unlock()
+
+		if before == after {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+	}
+}
+
+// Draws returns the number of draw functions executed so far.
+func (env *Env) Draws() int {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.draws
+}
+
+// Send injects an event into the Events() channel of the Env. It never blocks. Events sent
+// after the Env got closed are dropped.
+func (env *Env) Send(e gui.Event) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.closed {
+		return
+	}
+	env.eventsIn <- e
+}
+
+// Resize changes the drawing area of the Env to the rectangle r and produces a gui.Resize
+// event. The content of the old drawing area is preserved where the two areas overlap.
+func (env *Env) Resize(r image.Rectangle) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.closed {
+		return
+	}
+	img := image.NewRGBA(r)
+	draw.Draw(img, env.img.Bounds(), env.img, env.img.Bounds().Min, draw.Src)
+	env.img = img
+	env.damage = env.damage.Union(r)
+	env.eventsIn <- gui.Resize{Rectangle: r}
+}
+
+// Image returns a snapshot of the current content of the drawing area.
+func (env *Env) Image() *image.RGBA {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	img := image.NewRGBA(env.img.Bounds())
+	copy(img.Pix, env.img.Pix)
+	return img
+}
+
+// Damage returns the union of all rectangles returned by the draw functions (and areas changed
+// by Resize) since the Env was created, or since the last call to ClearDamage.
+func (env *Env) Damage() image.Rectangle {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.damage
+}
+
+// ClearDamage resets the damaged area reported by Damage to an empty rectangle.
+func (env *Env) ClearDamage() {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.damage = image.ZR
+}
@@ -0,0 +1,16 @@
+package headless_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/faiface/gui/envtest"
+	"github.com/faiface/gui/headless"
+)
+
+func TestConformance(t *testing.T) {
+	envtest.RunConformance(t, func(t *testing.T) envtest.Harness {
+		env := headless.New(image.Rect(0, 0, 640, 480))
+		return envtest.Harness{Env: env, Send: env.Send, Shutdown: env.Close}
+	})
+}
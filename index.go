@@ -0,0 +1,96 @@
+package gui
+
+import "image"
+
+const (
+	// indexCellSize is the size of the square cells of a spatialIndex.
+	indexCellSize = 64
+
+	// indexMaxCells is the maximum number of cells a rectangle gets stored in. Larger rectangles
+	// get checked for every point.
+	indexMaxCells = 1024
+)
+
+// spatialIndex finds the Envs whose rectangles contain a point without going through all of
+// them. It divides the plane into a grid of cells and remembers which rectangles overlap each
+// cell.
+type spatialIndex struct {
+	cells map[image.Point][]*muxEnv
+	large []*muxEnv
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+// cellRange returns the range of cells overlapped by r, min inclusive, max exclusive.
+func cellRange(r image.Rectangle) (min, max image.Point) {
+	min = image.Pt(floorDiv(r.Min.X, indexCellSize), floorDiv(r.Min.Y, indexCellSize))
+	max = image.Pt(floorDiv(r.Max.X-1, indexCellSize)+1, floorDiv(r.Max.Y-1, indexCellSize)+1)
+	return min, max
+}
+
+func (si *spatialIndex) insert(m *muxEnv, r image.Rectangle) {
+	if r.Empty() {
+		return
+	}
+	min, max := cellRange(r)
+	if (max.X-min.X)*(max.Y-min.Y) > indexMaxCells {
+		si.large = append(si.large, m)
+		return
+	}
+	if si.cells == nil {
+		si.cells = make(map[image.Point][]*muxEnv)
+	}
+	for y := min.Y; y < max.Y; y++ {
+		for x := min.X; x < max.X; x++ {
+			c := image.Pt(x, y)
+			si.cells[c] = append(si.cells[c], m)
+		}
+	}
+}
+
+func (si *spatialIndex) remove(m *muxEnv, r image.Rectangle) {
+	if r.Empty() {
+		return
+	}
+	min, max := cellRange(r)
+	if (max.X-min.X)*(max.Y-min.Y) > indexMaxCells {
+		si.large = removeEnv(si.large, m)
+		return
+	}
+	for y := min.Y; y < max.Y; y++ {
+		for x := min.X; x < max.X; x++ {
+			c := image.Pt(x, y)
+			if ms := removeEnv(si.cells[c], m); len(ms) > 0 {
+				si.cells[c] = ms
+			} else {
+				delete(si.cells, c)
+			}
+		}
+	}
+}
+
+// at calls f for each Env whose rectangle may contain the point p. The caller needs to check
+// whether it actually does.
+func (si *spatialIndex) at(p image.Point, f func(*muxEnv)) {
+	c := image.Pt(floorDiv(p.X, indexCellSize), floorDiv(p.Y, indexCellSize))
+	for _, m := range si.cells[c] {
+		f(m)
+	}
+	for _, m := range si.large {
+		f(m)
+	}
+}
+
+func removeEnv(ms []*muxEnv, m *muxEnv) []*muxEnv {
+	for i := range ms {
+		if ms[i] == m {
+			return append(ms[:i], ms[i+1:]...)
+		}
+	}
+	return ms
+}
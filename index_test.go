@@ -0,0 +1,93 @@
+package gui
+
+import (
+	"image"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// linearEnvAt is envAt as it was before the spatial index: it goes through the whole stack.
+func (mux *Mux) linearEnvAt(p image.Point) *muxEnv {
+	for i := len(mux.stack) - 1; i >= 0; i-- {
+		m := mux.stack[i]
+		if m.opts.bounded && p.In(m.opts.bounds) {
+			return m
+		}
+	}
+	return nil
+}
+
+func randomRect(rng *rand.Rand) image.Rectangle {
+	min := image.Pt(rng.Intn(1200)-200, rng.Intn(1200)-200)
+	switch rng.Intn(10) {
+	case 0:
+		// larger than indexMaxCells cells, so it doesn't go into the cells
+		return image.Rectangle{min, min.Add(image.Pt(3000, 3000))}
+	case 1:
+		return image.Rectangle{min, min}
+	}
+	return image.Rectangle{min, min.Add(image.Pt(rng.Intn(300)+1, rng.Intn(300)+1))}
+}
+
+func TestSpatialIndexRouting(t *testing.T) {
+	events, eventsIn := MakeEventsChan()
+	eventsIn <- Resize{image.Rect(0, 0, 1000, 1000)}
+	drawChan := make(chan func(draw.Image) image.Rectangle)
+	go func() {
+		for range drawChan {
+		}
+	}()
+	mux, master := NewMux(&envPair{events, drawChan})
+	defer close(master.Draw())
+
+	rng := rand.New(rand.NewSource(1))
+	var children []*Child
+	check := func(op string) {
+		t.Helper()
+		mux.mu.Lock()
+		defer mux.mu.Unlock()
+		for i := 0; i < 300; i++ {
+			p := image.Pt(rng.Intn(1400)-300, rng.Intn(1400)-300)
+			if got, want := mux.envAt(p), mux.linearEnvAt(p); got != want {
+				t.Fatalf("after %s: envAt(%v) = %p, want %p", op, p, got, want)
+			}
+		}
+	}
+
+	for i := 0; i < 40; i++ {
+		children = append(children, mux.MakeChild(Bounds(randomRect(rng))))
+	}
+	// the Envs without bounds are never found, until they get some with SetBounds
+	for i := 0; i < 5; i++ {
+		children = append(children, mux.MakeChild())
+	}
+	check("creating")
+
+	for i := 0; i < 300; i++ {
+		c := children[rng.Intn(len(children))]
+		var op string
+		switch rng.Intn(6) {
+		case 0:
+			op = "creating"
+			children = append(children, mux.MakeChild(Bounds(randomRect(rng))))
+		case 1:
+			op = "closing"
+			c.Close()
+		case 2:
+			op = "raising"
+			mux.Raise(c)
+		case 3:
+			op = "lowering"
+			mux.Lower(c)
+		default:
+			op = "moving"
+			c.SetBounds(randomRect(rng))
+		}
+		check(op)
+	}
+
+	for _, c := range children {
+		close(c.Draw())
+	}
+}
@@ -0,0 +1,71 @@
+// Package damage collects the changes made by draw functions until a backend flushes them to the
+// screen. It is shared by the backends drawing to an image, such as x11, fbdev, term and vnc.
+package damage
+
+import (
+	"image"
+	"time"
+)
+
+// FlushDelay is how long a Damage waits for more changes before they get flushed. It's short
+// enough not to delay any frame, while still merging the draw functions sent in a quick
+// succession, such as those of the components redrawing after the same event.
+const FlushDelay = time.Second / 960
+
+// Damage collects the rectangles changed by the draw functions sent to an Env until they get
+// flushed to the screen, once no more changes come for FlushDelay. It also implements the
+// Frames() channel reporting the flushes, see anim.Framer. Except for Frames, its methods must be
+// called from a single goroutine, usually the one executing the draw functions:
+//
+//	select {
+//	case d := <-w.draw:
+//		w.damage.Add(w.recovery.Draw(w, d, w.img))
+//	case <-w.damage.Ready():
+//		if r := w.damage.Take(w.img.Bounds()); !r.Empty() {
+//			w.upload(r)
+//			w.damage.Flushed()
+//		}
+//	}
+type Damage struct {
+	r      image.Rectangle
+	ready  <-chan time.Time
+	frames chan time.Time
+}
+
+// New creates a Damage with no changes.
+func New() *Damage {
+	return &Damage{frames: make(chan time.Time, 1)}
+}
+
+// Add adds a changed rectangle and postpones flushing the changes by FlushDelay.
+func (dm *Damage) Add(r image.Rectangle) {
+	dm.r = dm.r.Union(r)
+	dm.ready = time.After(FlushDelay)
+}
+
+// Ready returns a channel that receives once the changes should be flushed, or nil if nothing
+// was added since the last Take.
+func (dm *Damage) Ready() <-chan time.Time {
+	return dm.ready
+}
+
+// Take returns the changed part of bounds and forgets the changes.
+func (dm *Damage) Take(bounds image.Rectangle) image.Rectangle {
+	r := dm.r.Intersect(bounds)
+	dm.r, dm.ready = image.ZR, nil
+	return r
+}
+
+// Flushed reports a flush of the changes to the Frames() channel. It does not block, so the
+// frames nobody receives get dropped.
+func (dm *Damage) Flushed() {
+	select {
+	case dm.frames <- time.Now():
+	default:
+	}
+}
+
+// Frames returns the channel receiving the time of each flush.
+func (dm *Damage) Frames() <-chan time.Time {
+	return dm.frames
+}
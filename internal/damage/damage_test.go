@@ -0,0 +1,56 @@
+package damage
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func TestDamage(t *testing.T) {
+	dm := New()
+	if dm.Ready() != nil {
+		t.Fatal("an empty Damage is ready to be flushed")
+	}
+
+	dm.Add(image.Rect(0, 0, 10, 10))
+	dm.Add(image.Rect(5, 5, 30, 30))
+	select {
+	case <-dm.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Damage not ready within 1s")
+	}
+	if r := dm.Take(image.Rect(0, 0, 20, 20)); r != image.Rect(0, 0, 20, 20) {
+		t.Errorf("took %v, want the changes clipped to (0,0)-(20,20)", r)
+	}
+	if dm.Ready() != nil {
+		t.Error("Damage is ready to be flushed again after Take")
+	}
+	if r := dm.Take(image.Rect(0, 0, 20, 20)); !r.Empty() {
+		t.Errorf("took %v twice", r)
+	}
+
+	dm.Flushed()
+	dm.Flushed() // dropped, nobody receives
+	select {
+	case <-dm.Frames():
+	default:
+		t.Error("Flushed did not report a frame")
+	}
+	select {
+	case <-dm.Frames():
+		t.Error("Flushed reported a frame twice")
+	default:
+	}
+}
+
+func TestDamagePostponed(t *testing.T) {
+	dm := New()
+	start := time.Now()
+	dm.Add(image.Rect(0, 0, 1, 1))
+	time.Sleep(FlushDelay / 2)
+	dm.Add(image.Rect(1, 1, 2, 2)) // another change postpones the flush
+	<-dm.Ready()
+	if elapsed := time.Since(start); elapsed < FlushDelay*3/2 {
+		t.Errorf("ready %v after the first change, want at least %v", elapsed, FlushDelay*3/2)
+	}
+}
@@ -9,125 +9,668 @@ import (
 // Mux can be used to multiplex an Env, let's call it a root Env. Mux implements a way to
 // create multiple virtual Envs that all interact with the root Env. They receive the same
 // events and their draw functions get redirected to the root Env.
+//
+// Keyboard events are an exception: when an Env created by the Mux has the keyboard focus,
+// only that Env receives them. See Mux.Focus. Envs created with the Bounds option only receive
+// the mouse events happening inside their bounds.
+//
+// The Envs are stacked on top of each other, the newest on the top, see Mux.Raise. An Env
+// created with the Bounds option covers the part of all the Envs below it: they can't draw over
+// it and they don't receive the mouse events happening over it.
+//
+// An Env created with the Bounds option receives PointerEnter and PointerLeave events when the
+// mouse pointer crosses its bounds. Pressing a mouse button over it makes it capture the pointer:
+// it keeps receiving all the mouse events until all the buttons get released.
 type Mux struct {
 	mu         sync.Mutex
 	lastResize Event
-	eventsIns  []chan<- Event
-	draw       chan<- func(draw.Image) image.Rectangle
+	pointer    image.Point
+	envs       []*muxEnv // in the order of creation
+	unbounded  []*muxEnv // the Envs without bounds, in the order of creation
+	stack      []*muxEnv // from the bottom to the top
+	index      spatialIndex
+	focus      *muxEnv
+	shift      bool
+	hover      *muxEnv
+	capture    *muxEnv
+	pressed    int
+	draw       chan func(draw.Image) image.Rectangle
+
+	// the Mux gets closed when the master Env closes, or when the root Env closed its Events()
+	// channel and all the other Envs closed their Draw() channels, see Done and Wait
+	live         int // the number of Envs other than the master whose Draw() is open
+	open         int // the number of Envs whose Draw() is open and of other goroutines sending draws
+	eventsClosed bool
+	drawClosed   bool
+	closed       bool
+	closing      chan struct{}
+	finished     chan struct{}
+
+	// see Recover
+	recovering bool
+	panics     chan<- error
+
+	// while dispatching an event, the events to send get collected in pending and sent after
+	// unlocking mu, so that creating Envs doesn't have to wait for the sending
+	collecting bool
+	pending    []delivery
+
+	compositing bool
 }
 
 // NewMux creates a new Mux that multiplexes the given Env. It returns the Mux along with
 // a master Env. The master Env is just like any other Env created by the Mux, except that
 // closing the Draw() channel on the master Env closes the whole Mux and all other Envs
-// created by the Mux.
+// created by the Mux. See Mux.Wait for waiting until they all exit.
 func NewMux(env Env) (mux *Mux, master Env) {
-	drawChan := make(chan func(draw.Image) image.Rectangle)
-	mux = &Mux{draw: drawChan}
-	master = mux.makeEnv(true)
+	return newMux(env, false)
+}
+
+func newMux(env Env, compositing bool) (mux *Mux, master Env) {
+	mux = &Mux{
+		draw:        make(chan func(draw.Image) image.Rectangle),
+		closing:     make(chan struct{}),
+		finished:    make(chan struct{}),
+		compositing: compositing,
+	}
+	master = mux.makeEnv(true, nil)
 
 	go func() {
-		for d := range drawChan {
-			env.Draw() <- d
+		for {
+			select {
+			case d := <-mux.draw:
+				env.Draw() <- d
+			case <-mux.closing:
+				close(env.Draw())
+				mux.mu.Lock()
+				mux.drawClosed = true
+				mux.checkFinished()
+				mux.mu.Unlock()
+				return
+			}
 		}
-		close(env.Draw())
 	}()
 
 	go func() {
+		var pending []delivery
 		for e := range env.Events() {
 			mux.mu.Lock()
 			if resize, ok := e.(Resize); ok {
 				mux.lastResize = resize
 			}
-			for _, eventsIn := range mux.eventsIns {
-				eventsIn <- e
-			}
+			mux.collecting, mux.pending = true, pending[:0]
+			mux.dispatch(e)
+			mux.collecting, pending, mux.pending = false, mux.pending, nil
 			mux.mu.Unlock()
+
+			for i, d := range pending {
+				d.m.send(d.e)
+				pending[i] = delivery{}
+			}
 		}
 		mux.mu.Lock()
-		for _, eventsIn := range mux.eventsIns {
-			close(eventsIn)
+		mux.closeAll()
+		mux.eventsClosed = true
+		if mux.live == 0 {
+			mux.close()
 		}
+		mux.checkFinished()
 		mux.mu.Unlock()
 	}()
 
 	return mux, master
 }
 
+// Done returns a channel that gets closed when the Mux gets closed: when the master Env closes
+// its Draw() channel, or when the root Env closes its Events() channel and all the other Envs
+// close their Draw() channels. At that point, the Events() channels of all the Envs created by
+// the Mux are closed and the Draw() channel of the root Env gets closed.
+func (mux *Mux) Done() <-chan struct{} {
+	return mux.closing
+}
+
+// Wait blocks until the Mux is closed, every Env created by the Mux closed its Draw() channel
+// and the root Env closed its Events() channel. After Wait returns, no goroutines started by
+// the Mux are running.
+//
+// Envs created after the Mux got closed are not waited for. Their Events() channel is closed
+// right away.
+func (mux *Mux) Wait() {
+	<-mux.finished
+}
+
+// close closes the Mux, unless it got closed already. The draw functions sent afterwards get
+// ignored. It must be called with mux.mu locked.
+func (mux *Mux) close() {
+	if !mux.closed {
+		mux.closed = true
+		close(mux.closing)
+	}
+}
+
+// checkFinished closes the finished channel once everything is closed. It must be called with
+// mux.mu locked.
+func (mux *Mux) checkFinished() {
+	if mux.closed && mux.open == 0 && mux.drawClosed && mux.eventsClosed {
+		select {
+		case <-mux.finished:
+		default:
+			close(mux.finished)
+		}
+	}
+}
+
+type delivery struct {
+	m *muxEnv
+	e Event
+}
+
+// post sends the event to the Env, or schedules it to be sent after unlocking mux.mu when
+// dispatching. It must be called with mux.mu locked.
+func (mux *Mux) post(m *muxEnv, e Event) {
+	if mux.collecting {
+		mux.pending = append(mux.pending, delivery{m, e})
+		return
+	}
+	m.send(e)
+}
+
+// closeAll closes the Events() channels of all the Envs and removes them from the Mux. It must
+// be called with mux.mu locked.
+func (mux *Mux) closeAll() {
+	for _, m := range mux.envs {
+		m.closeEvents()
+	}
+	mux.envs = nil
+	mux.unbounded = nil
+	mux.stack = nil
+	mux.index = spatialIndex{}
+	mux.focus = nil
+	mux.hover = nil
+	mux.capture = nil
+}
+
+// remove closes the Events() channel of the Env and removes it from the Mux. It must be called
+// with mux.mu locked.
+func (mux *Mux) remove(m *muxEnv) {
+	i := 0
+	for i < len(mux.envs) && mux.envs[i] != m {
+		i++
+	}
+	if i == len(mux.envs) {
+		return // already removed by closeAll
+	}
+	// the focus moves on from a closed Focusable Env, just like with the Tab key
+	var nextFocus *muxEnv
+	if mux.focus == m && m.opts.focusable {
+		if nextFocus = mux.nextFocusable(m, true); nextFocus == m {
+			nextFocus = nil
+		}
+	}
+	mux.envs = append(mux.envs[:i], mux.envs[i+1:]...)
+	mux.unbounded = removeEnv(mux.unbounded, m)
+	if j := mux.stackIndex(m); j != -1 {
+		mux.stack = append(mux.stack[:j], mux.stack[j+1:]...)
+		mux.restack()
+	}
+	if m.opts.bounded {
+		mux.index.remove(m, m.opts.bounds)
+	}
+	if mux.focus == m {
+		mux.focus = nil
+		mux.setFocus(nextFocus)
+	}
+	if mux.hover == m {
+		mux.hover = nil
+	}
+	if mux.capture == m {
+		mux.capture = nil
+	}
+	m.closeEvents()
+
+	// the Envs below a closed Env with bounds need to redraw what it covered
+	if mux.compositing {
+		mux.recomposite(m.layerBounds())
+	} else if m.opts.bounded {
+		mux.expose(m.opts.bounds)
+	}
+}
+
+// dispatch sends an event from the root Env to the Envs it concerns. It must be called with
+// mux.mu locked.
+func (mux *Mux) dispatch(e Event) {
+	if pe, ok := e.(PointEvent); ok {
+		mux.pointer = pe.At()
+	}
+	if mux.dispatchKeyboard(e) {
+		return
+	}
+
+	// a mouse event only goes to the topmost Env with bounds under the mouse pointer, or to
+	// the Env that captured the pointer, and to the Envs without bounds stacked above it, other
+	// mouse events, such as scrolling, go there too
+	_, point := e.(PointEvent)
+	me, mouse := e.(MouseEvent)
+	mouse = mouse || point
+	var target *muxEnv
+	if mouse {
+		target = mux.capture
+		if target == nil {
+			target = mux.envAt(mux.pointer)
+		}
+	}
+
+	var button string
+	var down bool
+	if me != nil {
+		button, down = me.ButtonState()
+	}
+	release := button != "" && !down
+	if button != "" && down {
+		mux.press(target)
+	}
+	if point && !release {
+		mux.updateHover()
+	}
+
+	if mouse {
+		for _, m := range mux.unbounded {
+			// the target covers the Envs below it
+			if target != nil && m.z < target.z {
+				continue
+			}
+			mux.post(m, e)
+		}
+		if target != nil {
+			if pe, ok := e.(PointEvent); ok {
+				e = pe.Translate(image.ZP.Sub(target.origin()))
+			}
+			mux.post(target, e)
+		}
+	} else {
+		for _, m := range mux.envs {
+			if _, ok := e.(Resize); ok && m.opts.bounded {
+				mux.post(m, m.resize())
+				continue
+			}
+			mux.post(m, e)
+		}
+	}
+
+	if release {
+		mux.release()
+		mux.updateHover()
+	}
+}
+
+// envAt returns the topmost Env created with Bounds whose bounds contain the point, or nil.
+// It must be called with mux.mu locked.
+func (mux *Mux) envAt(p image.Point) *muxEnv {
+	var top *muxEnv
+	mux.index.at(p, func(m *muxEnv) {
+		if p.In(m.opts.bounds) && (top == nil || m.z > top.z) {
+			top = m
+		}
+	})
+	return top
+}
+
+// restack updates the positions of the Envs in the stack. It must be called with mux.mu locked.
+func (mux *Mux) restack() {
+	for i, m := range mux.stack {
+		m.z = i
+	}
+}
+
+// Raise moves the Env, which must have been created by the Mux, to the top of the stack of
+// Envs. The Env receives a Resize event, so that it redraws itself over the Envs it now covers,
+// unless the Mux is compositing.
+func (mux *Mux) Raise(env Env) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	i := mux.stackIndex(env)
+	if i == -1 {
+		return
+	}
+	m := mux.stack[i]
+	mux.stack = append(append(mux.stack[:i], mux.stack[i+1:]...), m)
+	mux.restack()
+	if mux.compositing {
+		mux.recomposite(m.layerBounds())
+		return
+	}
+	if e := mux.resizeOf(m); e != nil {
+		mux.post(m, e)
+	}
+}
+
+// Lower moves the Env, which must have been created by the Mux, to the bottom of the stack of
+// Envs. The Envs that covered the Env before receive a Resize event, so that they redraw
+// themselves, unless the Mux is compositing.
+func (mux *Mux) Lower(env Env) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	i := mux.stackIndex(env)
+	if i == -1 {
+		return
+	}
+	m := mux.stack[i]
+	below := append([]*muxEnv(nil), mux.stack[:i]...)
+	mux.stack = append(append([]*muxEnv{m}, below...), mux.stack[i+1:]...)
+	mux.restack()
+	if mux.compositing {
+		mux.recomposite(m.layerBounds())
+		return
+	}
+	for _, b := range below {
+		if !b.opts.bounded && !m.opts.bounded {
+			continue // neither covers the other
+		}
+		if e := mux.resizeOf(b); e != nil && b.area(mux).Overlaps(m.area(mux)) {
+			mux.post(b, e)
+		}
+	}
+}
+
+// stackIndex returns the position of the Env in the stack, or -1. It must be called with mux.mu
+// locked.
+func (mux *Mux) stackIndex(env Env) int {
+	env = unwrapEnv(env)
+	for i, m := range mux.stack {
+		if m == env {
+			return i
+		}
+	}
+	return -1
+}
+
+// resizeOf returns the Resize event the Env should currently receive, or nil if the size of
+// the root Env is not known yet. It must be called with mux.mu locked.
+func (mux *Mux) resizeOf(m *muxEnv) Event {
+	if m.opts.bounded {
+		return m.resize()
+	}
+	return mux.lastResize
+}
+
+// covered returns the parts of the area of the Env covered by the Envs above it. It must be
+// called with mux.mu locked.
+func (mux *Mux) covered(m *muxEnv) []image.Rectangle {
+	i := mux.stackIndex(m)
+	if i == -1 {
+		return nil
+	}
+	area := m.area(mux)
+	var rs []image.Rectangle
+	for _, above := range mux.stack[i+1:] {
+		if !above.opts.bounded {
+			continue
+		}
+		if r := above.opts.bounds.Intersect(area); !r.Empty() {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// expose sends a Resize event to all the Envs whose area overlaps r, so that they redraw it. It
+// must be called with mux.mu locked.
+func (mux *Mux) expose(r image.Rectangle) {
+	for _, m := range mux.envs {
+		if e := mux.resizeOf(m); e != nil && m.area(mux).Overlaps(r) {
+			mux.post(m, e)
+		}
+	}
+}
+
+// EnvOption is a functional option to Mux.MakeEnv.
+type EnvOption func(*envOptions)
+
+type envOptions struct {
+	focusable bool
+	bounded   bool
+	bounds    image.Rectangle
+	local     bool
+	coalesce  bool
+}
+
+// Bounds option restricts the Env to the rectangle r of the drawing area of the root Env.
+//
+// The Env reports r in its Resize events and only receives the mouse events happening inside r.
+// Its draw functions receive a draw.Image clipped to r, so they can't draw outside of it.
+func Bounds(r image.Rectangle) EnvOption {
+	return func(o *envOptions) {
+		o.bounded = true
+		o.bounds = r
+	}
+}
+
+// Local option moves the coordinates of an Env created with the Bounds option, so that the
+// top-left corner of its bounds becomes (0, 0). This applies to its events and its drawing, so
+// that a component doesn't need to know where it is placed.
+func Local() EnvOption {
+	return func(o *envOptions) {
+		o.local = true
+	}
+}
+
+// Coalesce option makes the Env merge consecutive mouse moves, scrolls and resizes which it
+// hasn't received yet. See MakeCoalescingEventsChan.
+func Coalesce() EnvOption {
+	return func(o *envOptions) {
+		o.coalesce = true
+	}
+}
+
+// Focusable option makes the Env take part in the keyboard focus traversal using the Tab key.
+// See Mux.Focus.
+func Focusable() EnvOption {
+	return func(o *envOptions) {
+		o.focusable = true
+	}
+}
+
 // MakeEnv creates a new virtual Env that interacts with the root Env of the Mux. Closing
 // the Draw() channel of the Env will not close the Mux, or any other Env created by the Mux
-// but will delete the Env from the Mux.
-func (mux *Mux) MakeEnv() Env {
-	return mux.makeEnv(false)
+// but will delete the Env from the Mux and close its Events() channel. See MakeChild for an Env
+// that can also be closed by the code that created it.
+func (mux *Mux) MakeEnv(opts ...EnvOption) Env {
+	return mux.makeEnv(false, opts)
 }
 
 type muxEnv struct {
 	events <-chan Event
 	draw   chan<- func(draw.Image) image.Rectangle
+	done   chan struct{}
+	self   Env        // the Env as returned to the user, see PanicError
+	opts   envOptions // the bounds are protected by mux.mu, see Child.SetBounds
+	z      int        // position in the stack, protected by mux.mu
+
+	mu       sync.Mutex // protects sending to eventsIn and closing it
+	eventsIn chan<- Event
+	closed   bool
+
+	// called when a draw function panics, see Supervise
+	onPanic func(*PanicError)
+
+	// used by a compositing Mux
+	buf     *image.RGBA
+	opacity float64
+	offset  image.Point
 }
 
 func (m *muxEnv) Events() <-chan Event                          { return m.events }
 func (m *muxEnv) Draw() chan<- func(draw.Image) image.Rectangle { return m.draw }
 
-func (mux *Mux) makeEnv(master bool) Env {
-	eventsOut, eventsIn := MakeEventsChan()
+// send sends an event to the Env, unless its Events() channel got closed already.
+func (m *muxEnv) send(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.eventsIn <- e
+	}
+}
+
+// isClosed reports whether the Events() channel of the Env got closed.
+func (m *muxEnv) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// closeEvents closes the Events() channel of the Env, unless it got closed already.
+func (m *muxEnv) closeEvents() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.eventsIn)
+	}
+}
+
+// origin returns the point of the root Env that is (0, 0) in the coordinates of the Env.
+func (m *muxEnv) origin() image.Point {
+	if m.opts.local {
+		return m.opts.bounds.Min
+	}
+	return image.ZP
+}
+
+// resize returns the Resize event reporting the bounds of an Env created with Bounds.
+func (m *muxEnv) resize() Resize {
+	return Resize{m.opts.bounds.Sub(m.origin())}
+}
+
+// area returns the part of the drawing area of the root Env the Env can draw to. It must be
+// called with mux.mu locked.
+func (m *muxEnv) area(mux *Mux) image.Rectangle {
+	if m.opts.bounded {
+		return m.opts.bounds
+	}
+	if resize, ok := mux.lastResize.(Resize); ok {
+		return resize.Rectangle
+	}
+	return image.ZR
+}
+
+// wrapDraw makes a draw function of the Env respect its bounds and the Envs stacked above it.
+func (mux *Mux) wrapDraw(m *muxEnv, d func(draw.Image) image.Rectangle) func(draw.Image) image.Rectangle {
+	return func(drw draw.Image) image.Rectangle {
+		mux.mu.Lock()
+		covered := mux.covered(m)
+		bounded, bounds, origin := m.opts.bounded, m.opts.bounds, m.origin()
+		mux.mu.Unlock()
+
+		// save the parts covered by the Envs above and restore them after drawing
+		saved := make([]*image.RGBA, len(covered))
+		for i, r := range covered {
+			saved[i] = image.NewRGBA(r)
+			draw.Draw(saved[i], r, drw, r.Min, draw.Src)
+		}
+
+		var r image.Rectangle
+		if bounded {
+			r = d(clipImage(drw, bounds, origin))
+			r = r.Add(origin).Intersect(bounds)
+		} else {
+			r = d(drw)
+		}
+
+		for i, cr := range covered {
+			if cr.Overlaps(r) {
+				draw.Draw(drw, cr, saved[i], cr.Min, draw.Src)
+			}
+		}
+
+		return r
+	}
+}
+
+func (mux *Mux) makeEnv(master bool, opts []EnvOption) *muxEnv {
+	var o envOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	eventsOut, eventsIn := makeEventsChan(o.coalesce)
 	drawChan := make(chan func(draw.Image) image.Rectangle)
-	env := &muxEnv{eventsOut, drawChan}
+	env := &muxEnv{
+		events:   eventsOut,
+		draw:     drawChan,
+		done:     make(chan struct{}),
+		eventsIn: eventsIn,
+		opts:     o,
+		opacity:  1,
+	}
+	env.self = env
 
 	mux.mu.Lock()
-	mux.eventsIns = append(mux.eventsIns, eventsIn)
+	tracked := !mux.closed
+	if tracked {
+		mux.open++
+		if !master {
+			mux.live++
+		}
+	}
 	// make sure to always send a resize event to a new Env if we got the size already
 	// that means it missed the resize event by the root Env
-	if mux.lastResize != nil {
+	if env.opts.bounded {
+		eventsIn <- env.resize()
+	} else if mux.lastResize != nil {
 		eventsIn <- mux.lastResize
 	}
+	if mux.eventsClosed || mux.closed {
+		// the root Env is gone already, there is nothing to receive
+		env.closed = true
+		close(eventsIn)
+	} else {
+		mux.envs = append(mux.envs, env)
+		env.z = len(mux.stack)
+		mux.stack = append(mux.stack, env)
+		if env.opts.bounded {
+			mux.index.insert(env, env.opts.bounds)
+		} else {
+			mux.unbounded = append(mux.unbounded, env)
+		}
+	}
 	mux.mu.Unlock()
 
 	go func() {
-		func() {
-			// When the master Env gets its Draw() channel closed, it closes all the Events()
-			// channels of all the children Envs, and it also closes the internal draw channel
-			// of the Mux. Otherwise, closing the Draw() channel of the master Env wouldn't
-			// close the Env the Mux is muxing. However, some child Envs of the Mux may still
-			// send some drawing commmands before they realize that their Events() channel got
-			// closed.
-			//
-			// That is perfectly fine if their drawing commands simply get ignored. This down here
-			// is a little hacky, but (I hope) perfectly fine solution to the problem.
-			//
-			// When the internal draw channel of the Mux gets closed, the line marked with ! will
-			// cause panic. We recover this panic, then we receive, but ignore all furhter draw
-			// commands, correctly draining the Env until it closes itself.
-			defer func() {
-				if recover() != nil {
-					for range drawChan {
-					}
-				}
-			}()
-			for d := range drawChan {
-				mux.draw <- d // !
+		for d := range drawChan {
+			// the draw functions of a closed Env get ignored, it may have been closed by
+			// Child.Close or by closing the Mux and not know about it yet
+			if env.isClosed() {
+				continue
 			}
-		}()
-		if master {
-			mux.mu.Lock()
-			for _, eventsIn := range mux.eventsIns {
-				close(eventsIn)
+			d = mux.protect(env, d)
+			if mux.compositing {
+				d = mux.compositeDraw(env, d)
+			} else {
+				d = mux.wrapDraw(env, d)
 			}
-			mux.eventsIns = nil
-			close(mux.draw)
-			mux.mu.Unlock()
+			select {
+			case mux.draw <- d:
+			case <-mux.closing:
+			}
+		}
+		mux.mu.Lock()
+		if master {
+			mux.closeAll()
+			mux.close()
 		} else {
-			mux.mu.Lock()
-			i := -1
-			for i = range mux.eventsIns {
-				if mux.eventsIns[i] == eventsIn {
-					break
-				}
+			mux.remove(env)
+			if tracked {
+				mux.live--
 			}
-			if i != -1 {
-				mux.eventsIns = append(mux.eventsIns[:i], mux.eventsIns[i+1:]...)
+			if mux.eventsClosed && mux.live == 0 {
+				mux.close()
 			}
-			mux.mu.Unlock()
 		}
+		if tracked {
+			mux.open--
+		}
+		mux.checkFinished()
+		mux.mu.Unlock()
+		close(env.done)
 	}()
 
 	return env
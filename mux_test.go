@@ -0,0 +1,216 @@
+package gui_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/envtest"
+	"github.com/faiface/gui/headless"
+	"github.com/faiface/gui/win"
+)
+
+// newMux creates a Mux of a headless Env, closing it at the end of the test unless the test
+// closes it by calling the returned function.
+func newMux(t *testing.T, r image.Rectangle) (*gui.Mux, *headless.Env, func()) {
+	root := headless.New(r)
+	mux, master := gui.NewMux(root)
+	go func() {
+		for range master.Events() {
+		}
+	}()
+	closed := false
+	shutdown := func() {
+		if !closed {
+			closed = true
+			close(master.Draw())
+		}
+	}
+	t.Cleanup(shutdown)
+	return mux, root, shutdown
+}
+
+func TestConformanceMuxEnv(t *testing.T) {
+	envtest.RunConformance(t, func(t *testing.T) envtest.Harness {
+		mux, root, shutdown := newMux(t, image.Rect(0, 0, 640, 480))
+		return envtest.Harness{Env: mux.MakeEnv(), Send: root.Send, Shutdown: shutdown}
+	})
+}
+
+func TestConformanceChild(t *testing.T) {
+	envtest.RunConformance(t, func(t *testing.T) envtest.Harness {
+		mux, root, _ := newMux(t, image.Rect(0, 0, 640, 480))
+		c := mux.MakeChild()
+		return envtest.Harness{Env: c, Send: root.Send, Shutdown: c.Close}
+	})
+}
+
+func TestConformanceBoundedChild(t *testing.T) {
+	envtest.RunConformance(t, func(t *testing.T) envtest.Harness {
+		mux, root, _ := newMux(t, image.Rect(0, 0, 640, 480))
+		c := mux.MakeChild(gui.Bounds(image.Rect(10, 10, 100, 100)))
+		return envtest.Harness{Env: c, Send: root.Send, Shutdown: c.Close}
+	})
+}
+
+// eventsUntil receives the events of the Env up to and including the one whose String is last.
+func eventsUntil(t *testing.T, env gui.Env, last string) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-env.Events():
+			if !ok {
+				t.Fatalf("Events() closed before %s, got %q", last, got)
+			}
+			got = append(got, e.String())
+			if e.String() == last {
+				return got
+			}
+		case <-timeout:
+			t.Fatalf("no %s within 5s, got %q", last, got)
+		}
+	}
+}
+
+func contains(events []string, s string) bool {
+	for _, e := range events {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBoundedEnvCoversUnboundedBelow(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	below := mux.MakeEnv()
+	popup := mux.MakeEnv(gui.Bounds(image.Rect(10, 10, 50, 50)))
+	above := mux.MakeEnv()
+
+	root.Send(win.MoDown{Point: image.Pt(20, 20), Button: win.ButtonLeft})
+	root.Send(win.MoUp{Point: image.Pt(20, 20), Button: win.ButtonLeft})
+	root.Send(win.MoMove{Point: image.Pt(80, 80)})
+
+	got := eventsUntil(t, below, "mo/move/80/80")
+	for _, e := range []string{"mo/down/20/20/left", "mo/up/20/20/left"} {
+		if contains(got, e) {
+			t.Errorf("the Env below the popup received %s, got %q", e, got)
+		}
+	}
+	got = eventsUntil(t, popup, "mo/up/20/20/left")
+	if !contains(got, "mo/down/20/20/left") {
+		t.Errorf("the popup did not receive the press, got %q", got)
+	}
+	got = eventsUntil(t, above, "mo/move/80/80")
+	if !contains(got, "mo/down/20/20/left") {
+		t.Errorf("the Env above the popup did not receive the press, got %q", got)
+	}
+}
+
+// drawSync sends the draw function to the Env and waits until it gets executed.
+func drawSync(t *testing.T, env gui.Env, d func(draw.Image) image.Rectangle) {
+	t.Helper()
+	done := make(chan struct{})
+	select {
+	case env.Draw() <- func(drw draw.Image) image.Rectangle {
+		defer close(done)
+		return d(drw)
+	}:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the Env did not receive a draw function within 5s")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the draw function did not get executed within 5s")
+	}
+}
+
+// checkFilled checks that exactly the rectangle r of the image has the color c.
+func checkFilled(t *testing.T, img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	t.Helper()
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := color.RGBA{}
+			if image.Pt(x, y).In(r) {
+				want = c
+			}
+			if got := img.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestBoundsClipsDrawing(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	bounds := image.Rect(10, 20, 50, 60)
+	env := mux.MakeEnv(gui.Bounds(bounds))
+
+	if got := eventsUntil(t, env, "resize/10/20/50/60"); len(got) != 1 {
+		t.Errorf("got %q before the Resize to the bounds", got)
+	}
+	root.ClearDamage()
+	drawSync(t, env, func(drw draw.Image) image.Rectangle {
+		if drw.Bounds() != bounds {
+			t.Errorf("drawing to %v, want the bounds %v", drw.Bounds(), bounds)
+		}
+		everything := image.Rect(-1000, -1000, 1000, 1000)
+		draw.Draw(drw, everything, &image.Uniform{red}, image.ZP, draw.Src)
+		return everything
+	})
+	checkFilled(t, root.Image(), bounds, red)
+	if got := root.Damage(); got != bounds {
+		t.Errorf("the root Env got %v changed, want the bounds %v", got, bounds)
+	}
+
+	// the events keep the coordinates of the root Env
+	root.Send(win.MoDown{Point: image.Pt(15, 25), Button: win.ButtonLeft})
+	root.Send(win.MoUp{Point: image.Pt(15, 25), Button: win.ButtonLeft})
+	if got := eventsUntil(t, env, "mo/up/15/25/left"); !contains(got, "mo/down/15/25/left") {
+		t.Errorf("got %q, want the click in the root coordinates", got)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	bounds := image.Rect(10, 20, 50, 60)
+	env := mux.MakeEnv(gui.Bounds(bounds), gui.Local())
+
+	// the top-left corner of the bounds is (0, 0) for the Env
+	if got := eventsUntil(t, env, "resize/0/0/40/40"); len(got) != 1 {
+		t.Errorf("got %q before the Resize to the local bounds", got)
+	}
+	root.ClearDamage()
+	drawSync(t, env, func(drw draw.Image) image.Rectangle {
+		if drw.Bounds() != image.Rect(0, 0, 40, 40) {
+			t.Errorf("drawing to %v, want (0,0)-(40,40)", drw.Bounds())
+		}
+		r := image.Rect(-10, -10, 5, 5)
+		draw.Draw(drw, r, &image.Uniform{red}, image.ZP, draw.Src)
+		return r
+	})
+	checkFilled(t, root.Image(), image.Rect(10, 20, 15, 25), red)
+	if got, want := root.Damage(), image.Rect(10, 20, 15, 25); got != want {
+		t.Errorf("the root Env got %v changed, want %v", got, want)
+	}
+
+	root.Send(win.MoMove{Point: image.Pt(12, 23)})
+	root.Send(win.MoDown{Point: image.Pt(49, 59), Button: win.ButtonLeft})
+	root.Send(win.MoScroll{Point: image.Pt(0, 1)})
+	root.Send(win.MoUp{Point: image.Pt(49, 59), Button: win.ButtonLeft})
+	got := eventsUntil(t, env, "mo/up/39/39/left")
+	for _, e := range []string{"pointer/enter/2/3", "mo/move/2/3", "mo/down/39/39/left", "mo/scroll/0/1"} {
+		if !contains(got, e) {
+			t.Errorf("got %q, want %s", got, e)
+		}
+	}
+}
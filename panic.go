@@ -0,0 +1,200 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is a panic recovered from a draw function or from a component.
+type PanicError struct {
+	Env   Env         // the Env the draw function was sent to, or the component was running in
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack trace of the panicking goroutine
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("gui: panic: %v", pe.Value)
+}
+
+// SafeDraw calls the draw function d sent to the Env with drw and returns the rectangle it
+// returned. If d panics, SafeDraw recovers the panic and returns it as a PanicError, along with
+// the bounds of drw, since d could have drawn anywhere before panicking.
+//
+// SafeDraw is meant for implementing Envs that recover panics in draw functions.
+func SafeDraw(env Env, d func(draw.Image) image.Rectangle, drw draw.Image) (r image.Rectangle, pe *PanicError) {
+	defer func() {
+		if v := recover(); v != nil {
+			r = drw.Bounds()
+			pe = &PanicError{Env: env, Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return d(drw), nil
+}
+
+// Recovery is the recovery of the panics in the draw functions sent to an Env, as set up by an
+// option like win.RecoverPanics. The zero Recovery doesn't recover anything.
+//
+// Recovery is meant for implementing Envs, along with SafeDraw.
+type Recovery struct {
+	Recover bool         // whether to recover the panics
+	Errs    chan<- error // where to report them, unless nil
+}
+
+// Draw calls the draw function d sent to the Env with drw and returns the rectangle it returned.
+// If the panics get recovered and d panics, Draw reports the PanicError from SafeDraw to Errs.
+// It does not block sending to Errs: if Errs is not ready, the error gets dropped.
+func (rc Recovery) Draw(env Env, d func(draw.Image) image.Rectangle, drw draw.Image) image.Rectangle {
+	if !rc.Recover {
+		return d(drw)
+	}
+	r, pe := SafeDraw(env, d, drw)
+	if pe != nil && rc.Errs != nil {
+		select {
+		case rc.Errs <- pe:
+		default:
+		}
+	}
+	return r
+}
+
+// Recover makes the Mux recover the panics in the draw functions sent by the Envs it created.
+// The Env whose draw function panicked gets closed, just like with Child.Close, and a PanicError
+// identifying it gets sent to errs, unless errs is nil.
+//
+// The Mux does not block sending to errs: if errs is not ready, the error gets dropped. Make
+// sure to use a buffered channel if that matters.
+func (mux *Mux) Recover(errs chan<- error) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.recovering = true
+	mux.panics = errs
+}
+
+// The delays between restarts of a component by Supervise.
+const (
+	superviseMinDelay = 10 * time.Millisecond
+	superviseMaxDelay = 5 * time.Second
+)
+
+// Supervise runs the component in a new Env created by the Mux with the options and blocks until
+// it finishes.
+//
+// Whenever the component panics, either in its own goroutine or in one of its draw functions,
+// Supervise closes its Env and runs the component again in a new one. The panic gets reported
+// just like with Recover. Supervise returns when the component returns without panicking, or
+// when the Mux gets closed.
+//
+// So that a component panicking right away doesn't keep restarting in a busy loop, Supervise
+// waits before each restart. The delay starts at 10ms and doubles with every restart, up to 5s.
+// It goes back to 10ms once the component runs for 5s without panicking.
+func (mux *Mux) Supervise(component func(Env), opts ...EnvOption) {
+	delay := superviseMinDelay
+	for {
+		started := time.Now()
+		if !mux.superviseOnce(component, opts) {
+			return
+		}
+
+		if time.Since(started) >= superviseMaxDelay {
+			delay = superviseMinDelay
+		}
+		select {
+		case <-time.After(delay):
+		case <-mux.Done():
+			return
+		}
+		if delay *= 2; delay > superviseMaxDelay {
+			delay = superviseMaxDelay
+		}
+	}
+}
+
+// superviseOnce runs the component in a new Env, just like Supervise, and reports whether it
+// panicked.
+func (mux *Mux) superviseOnce(component func(Env), opts []EnvOption) (panicked bool) {
+	c := mux.MakeChild(opts...)
+	failed := make(chan struct{}, 1)
+	mux.mu.Lock()
+	c.m.onPanic = func(*PanicError) {
+		select {
+		case failed <- struct{}{}:
+		default:
+		}
+	}
+	mux.mu.Unlock()
+
+	// the component gets its own Draw() channel, so that the Draw() channel of the Env gets
+	// closed even if the component panics before closing it
+	drawChan := make(chan func(draw.Image) image.Rectangle)
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		defer func() {
+			if v := recover(); v != nil {
+				mux.panicked(c.m, &PanicError{Env: c, Value: v, Stack: debug.Stack()})
+			}
+		}()
+		component(&envPair{c.Events(), drawChan})
+	}()
+
+forward:
+	for {
+		select {
+		case d, ok := <-drawChan:
+			if !ok {
+				break forward
+			}
+			c.Draw() <- d
+		case <-exited:
+			break forward
+		}
+	}
+	<-exited
+	close(c.Draw())
+	<-c.Done()
+
+	select {
+	case <-failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// protect makes the draw function of the Env recover its panics if the Mux recovers them.
+func (mux *Mux) protect(m *muxEnv, d func(draw.Image) image.Rectangle) func(draw.Image) image.Rectangle {
+	return func(drw draw.Image) image.Rectangle {
+		mux.mu.Lock()
+		recovering := mux.recovering || m.onPanic != nil
+		mux.mu.Unlock()
+		if !recovering {
+			return d(drw)
+		}
+		r, pe := SafeDraw(m.self, d, drw)
+		if pe != nil {
+			mux.panicked(m, pe)
+		}
+		return r
+	}
+}
+
+// panicked closes the Env and reports the panic.
+func (mux *Mux) panicked(m *muxEnv, pe *PanicError) {
+	mux.mu.Lock()
+	errs, onPanic := mux.panics, m.onPanic
+	mux.remove(m)
+	mux.mu.Unlock()
+
+	if onPanic != nil {
+		onPanic(pe)
+	}
+	if errs != nil {
+		select {
+		case errs <- pe:
+		default:
+		}
+	}
+}
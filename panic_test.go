@@ -0,0 +1,185 @@
+package gui_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+func panicky(draw.Image) image.Rectangle { panic("oops") }
+
+// panicError fails the test unless the channel receives a PanicError of the Env within a second.
+func panicError(t *testing.T, errs <-chan error, env gui.Env) {
+	t.Helper()
+	select {
+	case err := <-errs:
+		pe, ok := err.(*gui.PanicError)
+		if !ok || pe.Value != "oops" || pe.Env != env || len(pe.Stack) == 0 {
+			t.Errorf("got %#v, want the PanicError of the Env", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no PanicError within 1s")
+	}
+}
+
+func TestMuxRecover(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	errs := make(chan error, 1)
+	mux.Recover(errs)
+
+	env, child := mux.MakeEnv(), mux.MakeChild()
+	env.Draw() <- panicky
+	panicError(t, errs, env)
+	eventsClosed(t, env)
+	child.Draw() <- panicky
+	panicError(t, errs, child) // identified by the Child, not the Env behind it
+	eventsClosed(t, child)
+
+	// the other Envs keep working
+	other := mux.MakeEnv()
+	drawSync(t, other, fill(red))
+	if got := root.Image().RGBAAt(0, 0); got != red {
+		t.Errorf("pixel (0, 0) is %v, want the fill after the panics", got)
+	}
+
+	// errs is full, so the second error gets dropped instead of blocking
+	a, b := mux.MakeEnv(), mux.MakeEnv()
+	a.Draw() <- panicky
+	eventsClosed(t, a)
+	b.Draw() <- panicky
+	eventsClosed(t, b)
+	panicError(t, errs, a)
+}
+
+func TestSuperviseComponentPanic(t *testing.T) {
+	mux, _, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	errs := make(chan error, 1)
+	mux.Recover(errs)
+
+	var runs []gui.Env
+	wait(t, "Supervise", func() {
+		mux.Supervise(func(env gui.Env) {
+			runs = append(runs, env)
+			if len(runs) == 1 {
+				panic("oops")
+			}
+			close(env.Draw())
+		})
+	})
+	if len(runs) != 2 {
+		t.Fatalf("the component ran %d times, want once more after the panic", len(runs))
+	}
+	select {
+	case err := <-errs:
+		if pe, ok := err.(*gui.PanicError); !ok || pe.Value != "oops" {
+			t.Errorf("got %#v, want the PanicError of the component", err)
+		}
+	default:
+		t.Error("the panic of the component didn't get reported")
+	}
+}
+
+func TestSuperviseDrawPanic(t *testing.T) {
+	mux, _, _ := newMux(t, image.Rect(0, 0, 100, 100))
+
+	runs := 0
+	wait(t, "Supervise", func() {
+		mux.Supervise(func(env gui.Env) {
+			runs++
+			if runs == 1 {
+				env.Draw() <- panicky
+				for range env.Events() {
+				}
+			}
+			close(env.Draw())
+		})
+	})
+	if runs != 2 {
+		t.Errorf("the component ran %d times, want once more after the panic", runs)
+	}
+}
+
+func TestSuperviseCleanExit(t *testing.T) {
+	mux, _, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	runs := 0
+	wait(t, "Supervise", func() {
+		mux.Supervise(func(env gui.Env) {
+			runs++
+			close(env.Draw())
+		}, gui.Bounds(image.Rect(0, 0, 10, 10)))
+	})
+	if runs != 1 {
+		t.Errorf("the component ran %d times, want once", runs)
+	}
+}
+
+func TestSuperviseMuxClosed(t *testing.T) {
+	mux, _, shutdown := newMux(t, image.Rect(0, 0, 100, 100))
+	returned := make(chan struct{})
+	go func() {
+		mux.Supervise(drawing)
+		close(returned)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	shutdown()
+	closed(t, "Supervise", returned)
+}
+
+func TestSuperviseBackoff(t *testing.T) {
+	mux, _, shutdown := newMux(t, image.Rect(0, 0, 100, 100))
+	var runs int32
+	returned := make(chan struct{})
+	go func() {
+		mux.Supervise(func(gui.Env) {
+			atomic.AddInt32(&runs, 1)
+			panic("oops")
+		})
+		close(returned)
+	}()
+
+	// without a delay between restarts, there would be thousands of them
+	time.Sleep(300 * time.Millisecond)
+	if n := atomic.LoadInt32(&runs); n < 2 || n > 8 {
+		t.Errorf("the component ran %d times within 300ms, want a few with delays doubling from 10ms", n)
+	}
+
+	// the Mux closing interrupts the delay
+	shutdown()
+	closed(t, "Supervise", returned)
+}
+
+func TestRecovery(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	fine := func(draw.Image) image.Rectangle { return image.Rect(1, 1, 2, 2) }
+
+	var rc gui.Recovery
+	if r := rc.Draw(nil, fine, img); r != image.Rect(1, 1, 2, 2) {
+		t.Errorf("got %v, want the rectangle of the draw function", r)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the zero Recovery recovered a panic")
+			}
+		}()
+		rc.Draw(nil, panicky, img)
+	}()
+
+	errs := make(chan error, 1)
+	env := newRootEnv()
+	rc = gui.Recovery{Recover: true, Errs: errs}
+	if r := rc.Draw(env, panicky, img); r != img.Bounds() {
+		t.Errorf("got %v after a panic, want the bounds of the image", r)
+	}
+	rc.Draw(env, panicky, img) // errs is full, the error gets dropped
+	panicError(t, errs, env)
+
+	rc = gui.Recovery{Recover: true}
+	rc.Draw(nil, panicky, img) // no errs, nothing to report
+}
@@ -0,0 +1,121 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[string]func(string) (Event, error){}
+)
+
+func init() {
+	RegisterEvent("resize/", parseResize)
+}
+
+// ErrUnknownEvent is returned (wrapped) by ParseEvent when no registered prefix matches the
+// parsed string.
+var ErrUnknownEvent = errors.New("unknown event")
+
+// RegisterEvent registers a function parsing the strings produced by the String() method of
+// events of some kind. All such strings must start with the supplied prefix, for example
+// "resize/" or "mo/". ParseEvent then uses the function for all strings starting with the
+// prefix. If more registered prefixes match a string, the longest one wins.
+//
+// Packages implementing new kinds of events usually register them in their init function.
+// RegisterEvent panics if the prefix is empty, the function is nil, or the prefix is already
+// registered.
+func RegisterEvent(prefix string, parse func(s string) (Event, error)) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	if prefix == "" {
+		panic("gui: RegisterEvent with an empty prefix")
+	}
+	if parse == nil {
+		panic("gui: RegisterEvent with a nil parse function for " + prefix)
+	}
+	if _, dup := parsers[prefix]; dup {
+		panic("gui: RegisterEvent called twice for " + prefix)
+	}
+	parsers[prefix] = parse
+}
+
+// ParseEvent turns a string produced by the String() method of an event back into the event.
+// It is the inverse of String() for gui.Resize and all the kinds of events registered using
+// RegisterEvent.
+//
+// If no registered prefix matches the string, the returned error wraps ErrUnknownEvent.
+func ParseEvent(s string) (Event, error) {
+	parsersMu.RLock()
+	var (
+		longest string
+		parse   func(string) (Event, error)
+	)
+	for prefix, p := range parsers {
+		if strings.HasPrefix(s, prefix) && len(prefix) > len(longest) {
+			longest, parse = prefix, p
+		}
+	}
+	parsersMu.RUnlock()
+
+	if parse == nil {
+		return nil, fmt.Errorf("gui: %w %q (registered prefixes: %s)", ErrUnknownEvent, s, registeredPrefixes())
+	}
+	return parse(s)
+}
+
+func registeredPrefixes() string {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	var prefixes []string
+	for prefix := range parsers {
+		prefixes = append(prefixes, strconv.Quote(prefix))
+	}
+	sort.Strings(prefixes)
+	return strings.Join(prefixes, ", ")
+}
+
+// SplitEvent splits a string produced by the String() method of an event into its
+// slash-separated fields, checking that there are exactly n of them. It is useful for
+// implementing the parse functions supplied to RegisterEvent.
+func SplitEvent(s string, n int) ([]string, error) {
+	fields := strings.Split(s, "/")
+	if len(fields) != n {
+		return nil, fmt.Errorf("event %q: expected %d fields, got %d", s, n, len(fields))
+	}
+	return fields, nil
+}
+
+// ParseEventInts parses each of the fields as a decimal integer. It is useful for implementing
+// the parse functions supplied to RegisterEvent. The returned error wraps the error of
+// strconv.Atoi, such as strconv.ErrRange.
+func ParseEventInts(fields ...string) ([]int, error) {
+	ints := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q: %w", f, err)
+		}
+		ints[i] = n
+	}
+	return ints, nil
+}
+
+func parseResize(s string) (Event, error) {
+	fields, err := SplitEvent(s, 5)
+	if err != nil {
+		return nil, fmt.Errorf("gui: %v", err)
+	}
+	c, err := ParseEventInts(fields[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("gui: event %q: %w", s, err)
+	}
+	var r Resize
+	r.Min.X, r.Min.Y, r.Max.X, r.Max.Y = c[0], c[1], c[2], c[3]
+	return r, nil
+}
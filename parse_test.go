@@ -0,0 +1,139 @@
+package gui_test
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/anim"
+	"github.com/faiface/gui/win"
+)
+
+// events has an event of every kind registered by the gui, win and anim packages.
+var events = []gui.Event{
+	gui.Resize{Rectangle: image.Rect(-10, 0, 640, 480)},
+	gui.FocusGain{},
+	gui.FocusLose{},
+	gui.PointerEnter{Point: image.Pt(3, -4)},
+	gui.PointerLeave{Point: image.Pt(0, 0)},
+	win.WiClose{},
+	win.MoMove{Point: image.Pt(-5, 7)},
+	win.MoDown{Point: image.Pt(10, 20), Button: win.ButtonLeft},
+	win.MoDown{Point: image.Pt(10, 20), Button: win.ButtonMiddle},
+	win.MoUp{Point: image.Pt(10, 20), Button: win.ButtonRight},
+	win.MoScroll{Point: image.Pt(0, -3)},
+	win.KbType{Rune: 'a'},
+	win.KbType{Rune: 'ř'},
+	win.KbDown{Key: win.KeyEnter},
+	win.KbUp{Key: win.KeyShift},
+	win.KbRepeat{Key: win.KeyBackspace},
+	anim.Tick{Time: time.Unix(0, 1600000000123456789), Delta: 16 * time.Millisecond},
+}
+
+func TestParseEventRoundTrip(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, e := range events {
+		got, err := gui.ParseEvent(e.String())
+		if err != nil {
+			t.Errorf("ParseEvent(%q): %v", e, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, e) {
+			t.Errorf("ParseEvent(%q) = %#v, want %#v", e, got, e)
+		}
+		for _, prefix := range gui.RegisteredPrefixes() {
+			if strings.HasPrefix(e.String(), prefix) {
+				covered[prefix] = true
+			}
+		}
+	}
+	for _, prefix := range gui.RegisteredPrefixes() {
+		if !covered[prefix] {
+			t.Errorf("no round trip tested for the registered prefix %q", prefix)
+		}
+	}
+}
+
+func TestParseEventInvalid(t *testing.T) {
+	unknown := []string{"", "resize", "mo/", "foo/bar", "Resize/0/0/1/1"}
+	for _, s := range unknown {
+		if _, err := gui.ParseEvent(s); !errors.Is(err, gui.ErrUnknownEvent) {
+			t.Errorf("ParseEvent(%q): got error %v, want ErrUnknownEvent", s, err)
+		}
+	}
+
+	malformed := []string{
+		"resize/0/0/10",
+		"resize/0/0/10/10/10",
+		"resize/0/0/10/ten",
+		"focus/gained",
+		"pointer/enter/1",
+		"wi/closed",
+		"mo/move/1/2/3",
+		"mo/down/1/2/thumb",
+		"mo/up/1/2",
+		"kb/type/a",
+		"kb/down/hyper",
+		"kb/repeat/",
+		"anim/tick/1",
+		"anim/tick/1/soon",
+	}
+	for _, s := range malformed {
+		e, err := gui.ParseEvent(s)
+		if err == nil {
+			t.Errorf("ParseEvent(%q) = %v, want an error", s, e)
+		} else if errors.Is(err, gui.ErrUnknownEvent) {
+			t.Errorf("ParseEvent(%q): got %v, want an error other than ErrUnknownEvent", s, err)
+		}
+	}
+
+	for _, s := range []string{"resize/0/0/10/ten", "mo/move/x/1", "kb/type/99999999999999999999", "anim/tick/1/soon"} {
+		if _, err := gui.ParseEvent(s); !errors.Is(err, strconv.ErrSyntax) && !errors.Is(err, strconv.ErrRange) {
+			t.Errorf("ParseEvent(%q): got error %v, want one wrapping the strconv error", s, err)
+		}
+	}
+}
+
+func TestParseEventInts(t *testing.T) {
+	ints, err := gui.ParseEventInts("1", "-2", "+3")
+	if err != nil || !reflect.DeepEqual(ints, []int{1, -2, 3}) {
+		t.Errorf("ParseEventInts = %v, %v", ints, err)
+	}
+	_, err = gui.ParseEventInts("1", "x")
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || numErr.Num != "x" {
+		t.Errorf("got error %v, want a *strconv.NumError", err)
+	}
+}
+
+func TestRegisterEventPanics(t *testing.T) {
+	parse := func(s string) (gui.Event, error) { return nil, nil }
+	tests := []struct {
+		name   string
+		prefix string
+		parse  func(string) (gui.Event, error)
+	}{
+		{"duplicate", "resize/", parse},
+		{"duplicate from another package", "mo/down/", parse},
+		{"empty prefix", "", parse},
+		{"nil parse", "test/nil/", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterEvent(%q) did not panic", test.prefix)
+				}
+			}()
+			gui.RegisterEvent(test.prefix, test.parse)
+		})
+	}
+	if _, err := gui.ParseEvent("resize/0/0/1/1"); err != nil {
+		t.Errorf("the duplicate registration replaced the original parser: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+)
+
+// PointerEnter is an event that happens when the mouse pointer enters the bounds of an Env
+// created by a Mux with the Bounds option.
+type PointerEnter struct{ image.Point }
+
+// PointerLeave is an event that happens when the mouse pointer leaves the bounds of an Env
+// created by a Mux with the Bounds option.
+type PointerLeave struct{ image.Point }
+
+func (pe PointerEnter) String() string { return fmt.Sprintf("pointer/enter/%d/%d", pe.X, pe.Y) }
+func (pl PointerLeave) String() string { return fmt.Sprintf("pointer/leave/%d/%d", pl.X, pl.Y) }
+
+func (pe PointerEnter) At() image.Point { return pe.Point }
+func (pl PointerLeave) At() image.Point { return pl.Point }
+
+func (pe PointerEnter) Translate(delta image.Point) PointEvent {
+	return PointerEnter{pe.Point.Add(delta)}
+}
+
+func (pl PointerLeave) Translate(delta image.Point) PointEvent {
+	return PointerLeave{pl.Point.Add(delta)}
+}
+
+func init() {
+	RegisterEvent("pointer/enter/", parsePointer)
+	RegisterEvent("pointer/leave/", parsePointer)
+}
+
+func parsePointer(s string) (Event, error) {
+	fields, err := SplitEvent(s, 4)
+	if err != nil {
+		return nil, fmt.Errorf("gui: %v", err)
+	}
+	xy, err := ParseEventInts(fields[2:]...)
+	if err != nil {
+		return nil, fmt.Errorf("gui: event %q: %w", s, err)
+	}
+	p := image.Pt(xy[0], xy[1])
+	if fields[1] == "enter" {
+		return PointerEnter{p}, nil
+	}
+	return PointerLeave{p}, nil
+}
+
+// press handles a mouse button getting pressed over the target Env. The first pressed button
+// makes the target capture the pointer: it receives all the mouse events, even those outside of
+// its bounds, until all the buttons get released. It must be called with mux.mu locked.
+func (mux *Mux) press(target *muxEnv) {
+	if mux.pressed == 0 {
+		mux.capture = target
+	}
+	mux.pressed++
+}
+
+// release handles a mouse button getting released. It must be called with mux.mu locked.
+func (mux *Mux) release() {
+	if mux.pressed > 0 {
+		mux.pressed--
+	}
+	if mux.pressed == 0 {
+		mux.capture = nil
+	}
+}
+
+// updateHover sends PointerEnter and PointerLeave events if the Env under the mouse pointer
+// changed. While an Env captures the pointer, no other Env gets entered. It must be called with
+// mux.mu locked.
+func (mux *Mux) updateHover() {
+	hover := mux.envAt(mux.pointer)
+	if mux.capture != nil && hover != mux.capture {
+		hover = nil
+		if mux.pointer.In(mux.capture.opts.bounds) {
+			hover = mux.capture
+		}
+	}
+	if hover == mux.hover {
+		return
+	}
+	if mux.hover != nil {
+		mux.post(mux.hover, PointerLeave{mux.pointer.Sub(mux.hover.origin())})
+	}
+	mux.hover = hover
+	if mux.hover != nil {
+		mux.post(mux.hover, PointerEnter{mux.pointer.Sub(mux.hover.origin())})
+	}
+}
@@ -0,0 +1,108 @@
+package gui_test
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/win"
+)
+
+// pointer returns the pointer and mouse events of the Env up to and including the one whose
+// String is last.
+func pointer(t *testing.T, env gui.Env, last string) []string {
+	t.Helper()
+	var got []string
+	for _, e := range eventsUntil(t, env, last) {
+		if strings.HasPrefix(e, "pointer/") || strings.HasPrefix(e, "mo/") {
+			got = append(got, e)
+		}
+	}
+	return got
+}
+
+func TestPointerCapture(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	a := mux.MakeEnv(gui.Bounds(image.Rect(10, 10, 50, 50)))
+	b := mux.MakeEnv(gui.Bounds(image.Rect(60, 60, 100, 100)), gui.Local())
+
+	root.Send(win.MoDown{Point: image.Pt(20, 20), Button: win.ButtonLeft})
+	root.Send(win.MoMove{Point: image.Pt(80, 80)}) // over b, but a captured the pointer
+	root.Send(win.MoDown{Point: image.Pt(80, 80), Button: win.ButtonRight})
+	root.Send(win.MoUp{Point: image.Pt(80, 80), Button: win.ButtonLeft})
+	root.Send(win.MoMove{Point: image.Pt(30, 30)}) // still captured, back inside a
+	root.Send(win.MoUp{Point: image.Pt(30, 30), Button: win.ButtonRight})
+	root.Send(win.MoMove{Point: image.Pt(90, 90)}) // released, now b gets it
+	root.Send(win.MoMove{Point: image.Pt(95, 95)})
+
+	checkEvents(t, "a", pointer(t, a, "pointer/leave/90/90"),
+		"pointer/enter/20/20",
+		"mo/down/20/20/left",
+		"pointer/leave/80/80",
+		"mo/move/80/80",
+		"mo/down/80/80/right",
+		"mo/up/80/80/left",
+		"pointer/enter/30/30",
+		"mo/move/30/30",
+		"mo/up/30/30/right",
+		"pointer/leave/90/90",
+	)
+	checkEvents(t, "b", pointer(t, b, "mo/move/35/35"),
+		"pointer/enter/30/30",
+		"mo/move/30/30",
+		"mo/move/35/35",
+	)
+}
+
+func TestPointerCaptureOutside(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	a := mux.MakeEnv(gui.Bounds(image.Rect(10, 10, 50, 50)))
+
+	// pressing outside of any bounded Env captures nothing, so dragging into a enters it
+	root.Send(win.MoDown{Point: image.Pt(5, 5), Button: win.ButtonLeft})
+	root.Send(win.MoMove{Point: image.Pt(20, 20)})
+	root.Send(win.MoUp{Point: image.Pt(20, 20), Button: win.ButtonLeft})
+
+	checkEvents(t, "a", pointer(t, a, "mo/up/20/20/left"),
+		"pointer/enter/20/20",
+		"mo/move/20/20",
+		"mo/up/20/20/left",
+	)
+}
+
+func TestPointerEnterLeave(t *testing.T) {
+	mux, root, _ := newMux(t, image.Rect(0, 0, 100, 100))
+	left := mux.MakeEnv(gui.Bounds(image.Rect(0, 0, 50, 50)))
+	right := mux.MakeEnv(gui.Bounds(image.Rect(50, 0, 100, 50)), gui.Local())
+	popup := mux.MakeEnv(gui.Bounds(image.Rect(40, 10, 60, 20))) // above both
+
+	root.Send(win.MoMove{Point: image.Pt(10, 5)})
+	root.Send(win.MoMove{Point: image.Pt(49, 5)})
+	root.Send(win.MoMove{Point: image.Pt(50, 5)})  // left to right
+	root.Send(win.MoMove{Point: image.Pt(55, 15)}) // right to the popup
+	root.Send(win.MoMove{Point: image.Pt(45, 15)}) // still the popup, over left
+	root.Send(win.MoMove{Point: image.Pt(45, 25)}) // popup to left
+	root.Send(win.MoMove{Point: image.Pt(45, 75)}) // left to nothing
+
+	checkEvents(t, "left", pointer(t, left, "pointer/leave/45/75"),
+		"pointer/enter/10/5",
+		"mo/move/10/5",
+		"mo/move/49/5",
+		"pointer/leave/50/5",
+		"pointer/enter/45/25",
+		"mo/move/45/25",
+		"pointer/leave/45/75",
+	)
+	checkEvents(t, "right", pointer(t, right, "pointer/leave/5/15"),
+		"pointer/enter/0/5",
+		"mo/move/0/5",
+		"pointer/leave/5/15",
+	)
+	checkEvents(t, "popup", pointer(t, popup, "pointer/leave/45/25"),
+		"pointer/enter/55/15",
+		"mo/move/55/15",
+		"mo/move/45/15",
+		"pointer/leave/45/25",
+	)
+}
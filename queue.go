@@ -0,0 +1,61 @@
+package gui
+
+// minQueueCap is the capacity an eventQueue starts with and never shrinks below.
+const minQueueCap = 16
+
+// eventQueue is an unlimited FIFO queue of events backed by a ring buffer. It grows by doubling
+// when full and shrinks by halving when only a quarter full, so that the memory used during
+// a burst of events gets released afterwards, while steady traffic doesn't allocate at all.
+type eventQueue struct {
+	buf  []Event
+	head int
+	n    int
+}
+
+func (q *eventQueue) len() int { return q.n }
+
+// push adds an event to the end of the queue.
+func (q *eventQueue) push(e Event) {
+	if q.n == len(q.buf) {
+		q.resize(2 * len(q.buf))
+	}
+	q.buf[(q.head+q.n)%len(q.buf)] = e
+	q.n++
+}
+
+// front returns the first event in the queue. The queue must not be empty.
+func (q *eventQueue) front() Event {
+	return q.buf[q.head]
+}
+
+// pop removes the first event from the queue. The queue must not be empty.
+func (q *eventQueue) pop() {
+	q.buf[q.head] = nil // don't keep the event alive
+	q.head = (q.head + 1) % len(q.buf)
+	q.n--
+	if len(q.buf) > minQueueCap && q.n <= len(q.buf)/4 {
+		q.resize(len(q.buf) / 2)
+	}
+}
+
+// back returns a pointer to the last event in the queue. The queue must not be empty.
+func (q *eventQueue) back() *Event {
+	return &q.buf[(q.head+q.n-1)%len(q.buf)]
+}
+
+func (q *eventQueue) resize(capacity int) {
+	if capacity < minQueueCap {
+		capacity = minQueueCap
+	}
+	buf := make([]Event, capacity)
+	if q.n > 0 {
+		if q.head+q.n <= len(q.buf) {
+			copy(buf, q.buf[q.head:q.head+q.n])
+		} else {
+			k := copy(buf, q.buf[q.head:])
+			copy(buf[k:], q.buf[:q.n-k])
+		}
+	}
+	q.buf = buf
+	q.head = 0
+}
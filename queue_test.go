@@ -0,0 +1,172 @@
+package gui
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type testEvent int
+
+func (te testEvent) String() string { return fmt.Sprintf("test/%d", int(te)) }
+
+// popAll pops the events from the queue, checking that they continue the sequence from next.
+func popAll(t *testing.T, q *eventQueue, next int) int {
+	t.Helper()
+	for q.len() > 0 {
+		if e := q.front(); e != testEvent(next) {
+			t.Fatalf("got %v, want %v", e, testEvent(next))
+		}
+		q.pop()
+		next++
+	}
+	return next
+}
+
+func TestEventQueueWrapAround(t *testing.T) {
+	var q eventQueue
+	next, want := 0, 0
+	// keep the queue between 1 and minQueueCap-1 events long, so that it wraps around many
+	// times without ever growing
+	for i := 0; i < 10*minQueueCap; i++ {
+		for q.len() < minQueueCap-1 {
+			q.push(testEvent(next))
+			next++
+		}
+		for q.len() > 1 {
+			if e := q.front(); e != testEvent(want) {
+				t.Fatalf("got %v, want %v", e, testEvent(want))
+			}
+			q.pop()
+			want++
+		}
+		if len(q.buf) != minQueueCap {
+			t.Fatalf("capacity %d, want %d", len(q.buf), minQueueCap)
+		}
+	}
+	if want = popAll(t, &q, want); want != next {
+		t.Errorf("popped %d events, pushed %d", want, next)
+	}
+}
+
+func TestEventQueueGrowShrink(t *testing.T) {
+	var q eventQueue
+	// move the head to the middle, so that growing has to copy a wrapped around buffer
+	for i := 0; i < minQueueCap/2; i++ {
+		q.push(testEvent(-1))
+		q.pop()
+	}
+
+	next := 0
+	for i := 0; i < minQueueCap+1; i++ {
+		q.push(testEvent(next))
+		next++
+	}
+	if len(q.buf) != 2*minQueueCap {
+		t.Fatalf("capacity %d after %d pushes, want %d", len(q.buf), minQueueCap+1, 2*minQueueCap)
+	}
+	for q.len() < 8*minQueueCap {
+		q.push(testEvent(next))
+		next++
+	}
+	if len(q.buf) != 8*minQueueCap {
+		t.Fatalf("capacity %d with %d events, want %d", len(q.buf), q.len(), 8*minQueueCap)
+	}
+
+	want := 0
+	for q.len() > 2*minQueueCap+1 {
+		q.pop()
+		want++
+	}
+	if len(q.buf) != 8*minQueueCap {
+		t.Fatalf("capacity %d with %d events, want %d", len(q.buf), q.len(), 8*minQueueCap)
+	}
+	q.pop()
+	want++
+	if len(q.buf) != 4*minQueueCap {
+		t.Fatalf("capacity %d with %d events, want %d", len(q.buf), q.len(), 4*minQueueCap)
+	}
+
+	if want = popAll(t, &q, want); want != next {
+		t.Errorf("popped %d events, pushed %d", want, next)
+	}
+	if len(q.buf) != minQueueCap {
+		t.Errorf("capacity %d when empty, want %d", len(q.buf), minQueueCap)
+	}
+}
+
+func TestEventQueueClearsPopped(t *testing.T) {
+	var q eventQueue
+	for i := 0; i < 3; i++ {
+		q.push(testEvent(i))
+	}
+	q.pop()
+	q.pop()
+	for i, e := range q.buf {
+		if i != q.head && e != nil {
+			t.Errorf("slot %d keeps %v alive", i, e)
+		}
+	}
+}
+
+func TestMakeEventsChanOrder(t *testing.T) {
+	out, in := MakeEventsChan()
+	const n = 20000
+	go func() {
+		for i := 0; i < n; i++ {
+			in <- testEvent(i)
+		}
+		close(in)
+	}()
+
+	// a slow consumer lets the queue grow and shrink on the way
+	rnd := rand.New(rand.NewSource(1))
+	i := 0
+	for e := range out {
+		if e != testEvent(i) {
+			t.Fatalf("got %v, want %v", e, testEvent(i))
+		}
+		i++
+		if rnd.Intn(1000) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if i != n {
+		t.Errorf("received %d events, sent %d", i, n)
+	}
+}
+
+// benchmarkEventsChan sends b.N events in bursts of the given size, receiving each burst before
+// sending the next one, like steady traffic with a consumer keeping up.
+func benchmarkEventsChan(b *testing.B, makeChan func() (<-chan Event, chan<- Event), events []Event, burst int) {
+	b.ReportAllocs()
+	out, in := makeChan()
+	defer close(in)
+
+	b.ResetTimer()
+	for sent := 0; sent < b.N; sent += burst {
+		n := burst
+		if b.N-sent < n {
+			n = b.N - sent
+		}
+		for i := 0; i < n; i++ {
+			in <- events[(sent+i)%len(events)]
+		}
+		for i := 0; i < n; i++ {
+			<-out
+		}
+	}
+}
+
+func BenchmarkMakeEventsChan(b *testing.B) {
+	events := make([]Event, 64)
+	for i := range events {
+		events[i] = testEvent(i)
+	}
+	for _, burst := range []int{1, minQueueCap, 1024} {
+		b.Run(fmt.Sprintf("burst=%d", burst), func(b *testing.B) {
+			benchmarkEventsChan(b, MakeEventsChan, events, burst)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package gui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Record wraps an Env and writes every event it produces to w, one event per line, prefixed
+// by the time elapsed since the call to Record:
+//
+//	1.52s mo/down/10/20/left
+//
+// The returned Env produces the same events as the wrapped Env and draws to it. Errors writing
+// to w stop the recording, but not the Env.
+//
+// The recording can later be reproduced using Replay.
+func Record(env Env, w io.Writer) Env {
+	out, in := MakeEventsChan()
+	start := time.Now()
+
+	go func() {
+		var err error
+		for e := range env.Events() {
+			if err == nil {
+				_, err = fmt.Fprintf(w, "%v %v\n", time.Since(start), e)
+			}
+			in <- e
+		}
+		close(in)
+	}()
+
+	return &envPair{out, env.Draw()}
+}
+
+type recordedEvent struct {
+	at    time.Duration
+	event Event
+}
+
+// Replay wraps an Env and replaces its events with the events read from a recording made by
+// Record. The events of the wrapped Env are ignored, but drawing is done to it.
+//
+// The speed argument controls the timing: 1 reproduces the original timing, 2 makes it twice
+// as fast, and so on. A speed of 0 or less produces all the events at once.
+//
+// The returned Env closes its Events() channel after producing the last recorded event, or
+// when the wrapped Env closes. The whole recording is read and parsed before Replay returns.
+// Events are parsed using ParseEvent, so all the kinds of events in the recording must be
+// registered. If a kind of event is not, the returned error wraps ErrUnknownEvent.
+func Replay(env Env, r io.Reader, speed float64) (Env, error) {
+	var events []recordedEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("gui: replay: line %d: missing event", line)
+		}
+		at, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("gui: replay: line %d: %v", line, err)
+		}
+		e, err := ParseEvent(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("gui: replay: line %d: %w", line, err)
+		}
+		events = append(events, recordedEvent{at, e})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("gui: replay: %v", err)
+	}
+
+	out, in := MakeEventsChan()
+	stop := make(chan struct{})
+
+	go func() {
+		for range env.Events() {
+		}
+		close(stop)
+	}()
+
+	go func() {
+		defer close(in)
+		start := time.Now()
+		for _, re := range events {
+			if speed > 0 {
+				wait := time.Until(start.Add(time.Duration(float64(re.at) / speed)))
+				select {
+				case <-time.After(wait):
+				case <-stop:
+					return
+				}
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			in <- re.event
+		}
+	}()
+
+	return &envPair{out, env.Draw()}, nil
+}
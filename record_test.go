@@ -0,0 +1,120 @@
+package gui_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/headless"
+	"github.com/faiface/gui/win"
+)
+
+func TestRecordReplay(t *testing.T) {
+	sent := []gui.Event{
+		win.MoDown{Point: image.Pt(10, 20), Button: win.ButtonLeft},
+		win.KbType{Rune: 'a'},
+		win.MoUp{Point: image.Pt(10, 20), Button: win.ButtonLeft},
+	}
+	const gap = 30 * time.Millisecond
+
+	var rec bytes.Buffer
+	root := headless.New(image.Rect(0, 0, 100, 100))
+	env := gui.Record(root, &rec)
+	want := []string{(<-env.Events()).String()}
+	for _, e := range sent {
+		time.Sleep(gap)
+		root.Send(e)
+		want = append(want, (<-env.Events()).String())
+	}
+	close(env.Draw())
+	for range env.Events() {
+	}
+
+	// the recording has the events in order, with increasing offsets at least gap apart
+	lines := strings.Split(strings.TrimSpace(rec.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("recorded %d lines, want %d:\n%s", len(lines), len(want), rec.String())
+	}
+	var offsets []time.Duration
+	for i, line := range lines {
+		fields := strings.SplitN(line, " ", 2)
+		at, err := time.ParseDuration(fields[0])
+		if err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if fields[1] != want[i] {
+			t.Errorf("line %d: recorded %q, want %q", i+1, fields[1], want[i])
+		}
+		if i > 0 && at-offsets[i-1] < gap {
+			t.Errorf("line %d: %v after the previous event, want at least %v", i+1, at-offsets[i-1], gap)
+		}
+		offsets = append(offsets, at)
+	}
+
+	root = headless.New(image.Rect(0, 0, 100, 100))
+	replay, err := gui.Replay(root, bytes.NewReader(rec.Bytes()), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var got []string
+	for e := range replay.Events() {
+		elapsed := time.Since(start)
+		if i := len(got); i < len(offsets) && elapsed < offsets[i]-5*time.Millisecond {
+			t.Errorf("replayed %v after %v, recorded after %v", e, elapsed, offsets[i])
+		}
+		got = append(got, e.String())
+	}
+	close(replay.Draw())
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("replayed %q, want %q", got, want)
+	}
+	if last := offsets[len(offsets)-1]; time.Since(start) > last+time.Second {
+		t.Errorf("replay took %v, recorded %v", time.Since(start), last)
+	}
+}
+
+func TestReplayAtOnce(t *testing.T) {
+	rec := "0s resize/0/0/10/10\n\n1h kb/type/97\n"
+	replay, err := gui.Replay(headless.New(image.Rect(0, 0, 10, 10)), strings.NewReader(rec), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	timeout := time.After(time.Second)
+	for len(got) < 2 {
+		select {
+		case e := <-replay.Events():
+			got = append(got, e.String())
+		case <-timeout:
+			t.Fatalf("speed 0 did not replay everything at once, got %q", got)
+		}
+	}
+	close(replay.Draw())
+}
+
+func TestReplayMalformed(t *testing.T) {
+	tests := []struct {
+		rec, err string
+	}{
+		{"0s resize/0/0/10/10\nmo/down/1/2/left\n", "line 2: missing event"},
+		{"soon resize/0/0/10/10\n", "line 1: time: invalid duration"},
+		{"0s resize/0/0/10\n", "line 1: gui: event"},
+		{"0s\n", "line 1: missing event"},
+	}
+	for _, test := range tests {
+		_, err := gui.Replay(headless.New(image.Rect(0, 0, 10, 10)), strings.NewReader(test.rec), 1)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Replay(%q): got error %v, want one containing %q", test.rec, err, test.err)
+		}
+	}
+
+	_, err := gui.Replay(headless.New(image.Rect(0, 0, 10, 10)), strings.NewReader("0s no/such/event\n"), 1)
+	if !errors.Is(err, gui.ErrUnknownEvent) {
+		t.Errorf("Replay of an unknown event: got error %v, want ErrUnknownEvent", err)
+	}
+}
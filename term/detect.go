@@ -0,0 +1,102 @@
+package term
+
+import (
+	"bytes"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// detectTimeout is how long the detection waits for the replies of the terminal.
+const detectTimeout = time.Second
+
+// replies of the terminal to the detection queries
+var (
+	replyKitty    = regexp.MustCompile("\x1b_G([^\x1b]*)\x1b\\\\")
+	replyCellSize = regexp.MustCompile(`\x1b\[6;(\d+);(\d+)t`)
+	replyDA1      = regexp.MustCompile(`\x1b\[\?([\d;]*)c`)
+)
+
+// detect finds out the graphics supported by the terminal and the size of its cells, by
+// querying the terminal, unless they're already known. The primary device attributes (DA1) are
+// queried last, because every terminal replies to them, so the replies to the other queries
+// either come first, or not at all.
+//
+// It returns the input which arrived along with the replies.
+func (t *Term) detect(g Graphics, ws winsize) (Graphics, image.Point, []byte) {
+	if g == HalfBlocks {
+		return HalfBlocks, image.Pt(1, 2), nil
+	}
+
+	var cell image.Point
+	if ws.Col > 0 && ws.Row > 0 && ws.XPixel > 0 && ws.YPixel > 0 {
+		cell = image.Pt(int(ws.XPixel/ws.Col), int(ws.YPixel/ws.Row))
+	}
+
+	var query strings.Builder
+	if cell == image.ZP {
+		query.WriteString("\x1b[16t") // cell size in pixels
+	}
+	if g == Detect {
+		query.WriteString("\x1b_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\") // kitty graphics
+	}
+	query.WriteString("\x1b[c")
+	t.w.WriteString(query.String())
+	t.w.Flush()
+
+	var (
+		buf     []byte
+		timeout = time.After(detectTimeout)
+	)
+wait:
+	for !replyDA1.Match(buf) {
+		select {
+		case b, ok := <-t.input:
+			if !ok {
+				break wait
+			}
+			buf = append(buf, b...)
+		case <-timeout:
+			break wait
+		}
+	}
+
+	kitty, sixel := false, false
+	for _, m := range replyKitty.FindAllSubmatch(buf, -1) {
+		if bytes.HasPrefix(m[1], []byte("i=31;")) && bytes.HasSuffix(m[1], []byte(";OK")) {
+			kitty = true
+		}
+	}
+	if m := replyCellSize.FindSubmatch(buf); m != nil && cell == image.ZP {
+		h, _ := strconv.Atoi(string(m[1]))
+		w, _ := strconv.Atoi(string(m[2]))
+		cell = image.Pt(w, h)
+	}
+	if m := replyDA1.FindSubmatch(buf); m != nil {
+		for _, attr := range strings.Split(string(m[1]), ";") {
+			if attr == "4" {
+				sixel = true
+			}
+		}
+	}
+	for _, re := range []*regexp.Regexp{replyKitty, replyCellSize, replyDA1} {
+		buf = re.ReplaceAll(buf, nil)
+	}
+
+	if g == Detect {
+		switch {
+		case kitty:
+			g = Kitty
+		case sixel:
+			g = Sixel
+		default:
+			g = HalfBlocks
+		}
+	}
+	if g == HalfBlocks || cell.X <= 0 || cell.Y <= 0 {
+		return HalfBlocks, image.Pt(1, 2), buf
+	}
+	return g, cell, buf
+}
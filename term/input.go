@@ -0,0 +1,271 @@
+package term
+
+import (
+	"bytes"
+	"image"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/win"
+)
+
+const esc = 0x1b
+
+// keys of the escape sequences ending with a letter, such as "\x1b[A" or "\x1bOA"
+var letterKeys = map[byte]win.Key{
+	'A': win.KeyUp,
+	'B': win.KeyDown,
+	'C': win.KeyRight,
+	'D': win.KeyLeft,
+	'H': win.KeyHome,
+	'F': win.KeyEnd,
+}
+
+// keys of the escape sequences ending with a tilde, such as "\x1b[3~"
+var tildeKeys = map[string]win.Key{
+	"1": win.KeyHome,
+	"3": win.KeyDelete,
+	"4": win.KeyEnd,
+	"5": win.KeyPageUp,
+	"6": win.KeyPageDown,
+	"7": win.KeyHome,
+	"8": win.KeyEnd,
+}
+
+// parser translates the input from the terminal to events.
+type parser struct {
+	emit   func(gui.Event)
+	cell   image.Point     // size of a cell in pixels
+	bounds image.Rectangle // the mouse is clamped to it
+	pos    image.Point     // the last position of the mouse
+	buf    []byte
+}
+
+// feed parses the input, along with the unparsed input from before. It returns true if the input
+// ends with an incomplete escape sequence or character, which is kept until more input arrives.
+func (p *parser) feed(b []byte) (incomplete bool) {
+	p.buf = append(p.buf, b...)
+	for len(p.buf) > 0 {
+		n := p.parse(p.buf)
+		if n == 0 {
+			return true
+		}
+		p.buf = p.buf[n:]
+	}
+	p.buf = p.buf[:0]
+	return false
+}
+
+// flush gives up on waiting for the rest of an incomplete escape sequence. Its ESC is taken as
+// the Escape key and the rest as typed keys.
+func (p *parser) flush() {
+	for len(p.buf) > 0 {
+		if p.buf[0] == esc {
+			p.key(win.KeyEscape)
+		}
+		p.buf = p.buf[1:]
+		if !p.feed(nil) {
+			return
+		}
+	}
+}
+
+// parse parses one key, or one escape sequence, from the beginning of b and returns its length,
+// or zero if it's incomplete.
+func (p *parser) parse(b []byte) int {
+	if b[0] != esc {
+		n := charLen(b)
+		if n > 0 {
+			p.char(b[:n])
+		}
+		return n
+	}
+	if len(b) < 2 {
+		return 0
+	}
+
+	switch b[1] {
+	case '[':
+		return p.csi(b)
+
+	case 'O':
+		if len(b) < 3 {
+			return 0
+		}
+		if k, ok := letterKeys[b[2]]; ok {
+			p.key(k)
+		}
+		return 3
+
+	case '_', 'P':
+		// replies to queries, such as those of the kitty graphics protocol, end with ST
+		i := bytes.Index(b[2:], []byte("\x1b\\"))
+		if i < 0 {
+			return 0
+		}
+		return 2 + i + 2
+
+	case esc:
+		p.key(win.KeyEscape)
+		return 1
+	}
+
+	// ESC followed by a key is the key pressed with Alt
+	n := charLen(b[1:])
+	if n == 0 {
+		return 0
+	}
+	p.emit(win.KbDown{Key: win.KeyAlt})
+	p.char(b[1 : 1+n])
+	p.emit(win.KbUp{Key: win.KeyAlt})
+	return 1 + n
+}
+
+// charLen returns the length of the UTF-8 encoded character at the beginning of b, or zero if
+// it's incomplete.
+func charLen(b []byte) int {
+	if b[0] < utf8.RuneSelf {
+		return 1
+	}
+	if !utf8.FullRune(b) {
+		return 0
+	}
+	_, n := utf8.DecodeRune(b)
+	return n
+}
+
+// char handles a single character typed on the keyboard.
+func (p *parser) char(b []byte) {
+	switch b[0] {
+	case '\r', '\n':
+		p.key(win.KeyEnter)
+	case '\t':
+		p.key(win.KeyTab)
+	case 0x7f, 0x08:
+		p.key(win.KeyBackspace)
+	case 0x03: // Ctrl+C
+		p.emit(win.WiClose{})
+	case ' ':
+		p.emit(win.KbDown{Key: win.KeySpace})
+		p.emit(win.KbType{Rune: ' '})
+		p.emit(win.KbUp{Key: win.KeySpace})
+	default:
+		if b[0] < 0x20 {
+			return // other control characters
+		}
+		if r, _ := utf8.DecodeRune(b); r != utf8.RuneError {
+			p.emit(win.KbType{Rune: r})
+		}
+	}
+}
+
+func (p *parser) key(k win.Key) {
+	p.emit(win.KbDown{Key: k})
+	p.emit(win.KbUp{Key: k})
+}
+
+// csi handles a control sequence: ESC [, parameters and a final byte.
+func (p *parser) csi(b []byte) int {
+	i := 2
+	for i < len(b) && b[i] >= 0x20 && b[i] <= 0x3f {
+		i++
+	}
+	if i == len(b) {
+		return 0
+	}
+	params, final := string(b[2:i]), b[i]
+
+	switch {
+	case strings.HasPrefix(params, "<") && (final == 'M' || final == 'm'):
+		p.mouse(params[1:], final == 'M')
+	case final == '~':
+		if k, ok := tildeKeys[strings.Split(params, ";")[0]]; ok {
+			p.key(k)
+		}
+	case final == 'Z':
+		// Shift+Tab, reported as a key of its own
+		p.emit(win.KbDown{Key: win.KeyShift})
+		p.key(win.KeyTab)
+		p.emit(win.KbUp{Key: win.KeyShift})
+	default:
+		// modifiers, such as in "\x1b[1;5C", are ignored
+		if k, ok := letterKeys[final]; ok && !strings.HasPrefix(params, "?") {
+			p.key(k)
+		}
+	}
+	return i + 1
+}
+
+// mouse handles an SGR mouse report: button, column and row, pressed (M) or released (m).
+func (p *parser) mouse(params string, pressed bool) {
+	f := strings.Split(params, ";")
+	if len(f) != 3 {
+		return
+	}
+	var n [3]int
+	for i := range f {
+		var err error
+		if n[i], err = strconv.Atoi(f[i]); err != nil {
+			return
+		}
+	}
+	b, col, row := n[0], n[1], n[2]
+
+	// the center of the cell, clamped to the bounds
+	pt := image.Pt((col-1)*p.cell.X+p.cell.X/2, (row-1)*p.cell.Y+p.cell.Y/2)
+	if !p.bounds.Empty() {
+		pt.X = clamp(pt.X, p.bounds.Min.X, p.bounds.Max.X-1)
+		pt.Y = clamp(pt.Y, p.bounds.Min.Y, p.bounds.Max.Y-1)
+	}
+
+	if b&64 != 0 {
+		// the same directions as the scroll buttons of the X server
+		switch b & 3 {
+		case 0:
+			p.emit(win.MoScroll{Point: image.Pt(0, 1)})
+		case 1:
+			p.emit(win.MoScroll{Point: image.Pt(0, -1)})
+		case 2:
+			p.emit(win.MoScroll{Point: image.Pt(1, 0)})
+		case 3:
+			p.emit(win.MoScroll{Point: image.Pt(-1, 0)})
+		}
+		return
+	}
+
+	if pt != p.pos {
+		p.pos = pt
+		p.emit(win.MoMove{Point: pt})
+	}
+	if b&32 != 0 {
+		return // motion
+	}
+	var btn win.Button
+	switch b & 3 {
+	case 0:
+		btn = win.ButtonLeft
+	case 1:
+		btn = win.ButtonMiddle
+	case 2:
+		btn = win.ButtonRight
+	default:
+		return
+	}
+	if pressed {
+		p.emit(win.MoDown{Point: pt, Button: btn})
+	} else {
+		p.emit(win.MoUp{Point: pt, Button: btn})
+	}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
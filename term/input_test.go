@@ -0,0 +1,90 @@
+package term
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/faiface/gui"
+)
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name       string
+		reads      []string
+		incomplete bool // whether the last read ends with an incomplete sequence
+		flush      bool // flush after the reads, as when escDelay passes
+		want       []string
+	}{
+		{name: "characters", reads: []string{"a1 é"},
+			want: []string{"kb/type/97", "kb/type/49", "kb/down/space", "kb/type/32", "kb/up/space", "kb/type/233"}},
+		{name: "control characters", reads: []string{"\r\t\x7f\x01\x03"},
+			want: []string{"kb/down/enter", "kb/up/enter", "kb/down/tab", "kb/up/tab", "kb/down/backspace", "kb/up/backspace", "wi/close"}},
+		{name: "UTF-8 split across reads", reads: []string{"\xc3", "\xa9\xe2\x82", "\xac"},
+			want: []string{"kb/type/233", "kb/type/8364"}},
+		{name: "incomplete UTF-8", reads: []string{"a\xe2\x82"}, incomplete: true,
+			want: []string{"kb/type/97"}},
+
+		{name: "arrows", reads: []string{"\x1b[A\x1bOB\x1b[1;5C"},
+			want: []string{"kb/down/up", "kb/up/up", "kb/down/down", "kb/up/down", "kb/down/right", "kb/up/right"}},
+		{name: "delete", reads: []string{"\x1b[3~"},
+			want: []string{"kb/down/delete", "kb/up/delete"}},
+		{name: "tilde keys with modifiers", reads: []string{"\x1b[5;5~\x1b[6~"},
+			want: []string{"kb/down/pageup", "kb/up/pageup", "kb/down/pagedown", "kb/up/pagedown"}},
+		{name: "sequence split across reads", reads: []string{"\x1b", "[", "3~"},
+			want: []string{"kb/down/delete", "kb/up/delete"}},
+		{name: "Shift+Tab", reads: []string{"\x1b[Z"},
+			want: []string{"kb/down/shift", "kb/down/tab", "kb/up/tab", "kb/up/shift"}},
+		{name: "query reply", reads: []string{"\x1b_Gi=31;OK", "\x1b\\x"},
+			want: []string{"kb/type/120"}},
+
+		{name: "lone ESC", reads: []string{"\x1b"}, incomplete: true,
+			want: nil},
+		{name: "lone ESC timeout", reads: []string{"\x1b"}, incomplete: true, flush: true,
+			want: []string{"kb/down/escape", "kb/up/escape"}},
+		{name: "double ESC", reads: []string{"\x1b\x1b"}, incomplete: true, flush: true,
+			want: []string{"kb/down/escape", "kb/up/escape", "kb/down/escape", "kb/up/escape"}},
+		{name: "incomplete sequence timeout", reads: []string{"\x1b[1;"}, incomplete: true, flush: true,
+			want: []string{"kb/down/escape", "kb/up/escape", "kb/type/91", "kb/type/49", "kb/type/59"}},
+		{name: "Alt prefix", reads: []string{"\x1bx\x1b\r"},
+			want: []string{"kb/down/alt", "kb/type/120", "kb/up/alt", "kb/down/alt", "kb/down/enter", "kb/up/enter", "kb/up/alt"}},
+		{name: "Alt prefix with UTF-8", reads: []string{"\x1b\xc3", "\xa9"},
+			want: []string{"kb/down/alt", "kb/type/233", "kb/up/alt"}},
+
+		// the cells are 2x4 pixels, the events point to their centers
+		{name: "mouse press and release", reads: []string{"\x1b[<0;5;3M\x1b[<0;5;3m"},
+			want: []string{"mo/move/9/10", "mo/down/9/10/left", "mo/up/9/10/left"}},
+		{name: "mouse buttons", reads: []string{"\x1b[<1;1;1M\x1b[<2;1;1M\x1b[<2;1;1m"},
+			want: []string{"mo/move/1/2", "mo/down/1/2/middle", "mo/down/1/2/right", "mo/up/1/2/right"}},
+		{name: "mouse motion", reads: []string{"\x1b[<35;6;3M\x1b[<32;7;3M\x1b[<35;7;3M"},
+			want: []string{"mo/move/11/10", "mo/move/13/10"}},
+		{name: "mouse wheel", reads: []string{"\x1b[<64;1;1M\x1b[<65;1;1M\x1b[<66;1;1M\x1b[<67;1;1M"},
+			want: []string{"mo/scroll/0/1", "mo/scroll/0/-1", "mo/scroll/1/0", "mo/scroll/-1/0"}},
+		{name: "mouse clamped", reads: []string{"\x1b[<0;100;100M"},
+			want: []string{"mo/move/39/39", "mo/down/39/39/left"}},
+		{name: "mouse split across reads", reads: []string{"\x1b[<0;5", ";3M"},
+			want: []string{"mo/move/9/10", "mo/down/9/10/left"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		p := &parser{
+			emit:   func(e gui.Event) { got = append(got, e.String()) },
+			cell:   image.Pt(2, 4),
+			bounds: image.Rect(0, 0, 40, 40),
+		}
+		var incomplete bool
+		for _, read := range tt.reads {
+			incomplete = p.feed([]byte(read))
+		}
+		if incomplete != tt.incomplete {
+			t.Errorf("%s: feed returned %v, want %v", tt.name, incomplete, tt.incomplete)
+		}
+		if tt.flush {
+			p.flush()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package term
+
+import (
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"strconv"
+)
+
+// kittyTile is the size of the tiles, in cells, the image is split into with the kitty graphics
+// protocol. Each tile is a separate image with a fixed id, replaced whenever it changes, so that
+// the terminal doesn't keep the images which got drawn over.
+var kittyTile = image.Pt(16, 8)
+
+// render writes the rectangle r of the image to the terminal, extended to whole cells.
+func (t *Term) render(r image.Rectangle) {
+	bounds := t.cellBounds()
+	cells := image.Rect(
+		r.Min.X/t.cell.X, r.Min.Y/t.cell.Y,
+		(r.Max.X+t.cell.X-1)/t.cell.X, (r.Max.Y+t.cell.Y-1)/t.cell.Y,
+	).Intersect(bounds)
+
+	switch t.graphics {
+	case HalfBlocks:
+		t.halfBlocks(cells)
+	case Sixel:
+		t.sixel(cells)
+	case Kitty:
+		columns := (bounds.Dx() + kittyTile.X - 1) / kittyTile.X
+		for ty := cells.Min.Y / kittyTile.Y; ty*kittyTile.Y < cells.Max.Y; ty++ {
+			for tx := cells.Min.X / kittyTile.X; tx*kittyTile.X < cells.Max.X; tx++ {
+				tile := image.Rect(tx*kittyTile.X, ty*kittyTile.Y, (tx+1)*kittyTile.X, (ty+1)*kittyTile.Y)
+				t.kitty(tile.Intersect(bounds), 1+ty*columns+tx)
+			}
+		}
+	}
+}
+
+// cellBounds returns the bounds of the image in cells.
+func (t *Term) cellBounds() image.Rectangle {
+	size := t.img.Bounds().Size()
+	return image.Rect(0, 0, size.X/t.cell.X, size.Y/t.cell.Y)
+}
+
+// pixels returns the rectangle of the image covered by the cells.
+func (t *Term) pixels(cells image.Rectangle) image.Rectangle {
+	return image.Rect(
+		cells.Min.X*t.cell.X, cells.Min.Y*t.cell.Y,
+		cells.Max.X*t.cell.X, cells.Max.Y*t.cell.Y,
+	)
+}
+
+// moveTo moves the cursor to the cell.
+func (t *Term) moveTo(cell image.Point) {
+	fmt.Fprintf(t.w, "\x1b[%d;%dH", cell.Y+1, cell.X+1)
+}
+
+// halfBlocks draws the cells using upper half blocks with the color of the upper pixel in the
+// foreground and the color of the lower one in the background.
+func (t *Term) halfBlocks(cells image.Rectangle) {
+	for cy := cells.Min.Y; cy < cells.Max.Y; cy++ {
+		t.moveTo(image.Pt(cells.Min.X, cy))
+		for cx := cells.Min.X; cx < cells.Max.X; cx++ {
+			fg, bg := t.img.RGBAAt(cx, 2*cy), t.img.RGBAAt(cx, 2*cy+1)
+			if cx == cells.Min.X || fg != t.img.RGBAAt(cx-1, 2*cy) {
+				fmt.Fprintf(t.w, "\x1b[38;2;%d;%d;%dm", fg.R, fg.G, fg.B)
+			}
+			if cx == cells.Min.X || bg != t.img.RGBAAt(cx-1, 2*cy+1) {
+				fmt.Fprintf(t.w, "\x1b[48;2;%d;%d;%dm", bg.R, bg.G, bg.B)
+			}
+			t.w.WriteString("▀")
+		}
+	}
+	t.w.WriteString("\x1b[0m")
+}
+
+// level returns the level, 0 to 5, of a color component in the 6x6x6 color cube of sixels.
+func level(v uint8) int {
+	return (int(v)*5 + 127) / 255
+}
+
+// sixel draws the cells as a single sixel image with the colors of a 6x6x6 color cube.
+func (t *Term) sixel(cells image.Rectangle) {
+	r := t.pixels(cells)
+	w, h := r.Dx(), r.Dy()
+
+	if cap(t.indices) < w*h {
+		t.indices = make([]uint8, w*h)
+	}
+	idx := t.indices[:w*h]
+	var used [216]bool
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := t.img.RGBAAt(r.Min.X+x, r.Min.Y+y)
+			i := level(c.R)*36 + level(c.G)*6 + level(c.B)
+			idx[y*w+x] = uint8(i)
+			used[i] = true
+		}
+	}
+
+	t.moveTo(cells.Min)
+	// P2=1 keeps the pixels not drawn, the raster attributes set 1:1 pixels and the size
+	fmt.Fprintf(t.w, "\x1bP0;1;0q\"1;1;%d;%d", w, h)
+	for i := range used {
+		if used[i] {
+			fmt.Fprintf(t.w, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+		}
+	}
+
+	var (
+		colors []int
+		inBand [216]bool
+	)
+	for y0 := 0; y0 < h; y0 += 6 {
+		// a band of six rows, drawn in each of its colors separately
+		for dy := 0; dy < 6 && y0+dy < h; dy++ {
+			for x := 0; x < w; x++ {
+				i := idx[(y0+dy)*w+x]
+				if len(t.sixels[i]) != w {
+					t.sixels[i] = make([]byte, w)
+				}
+				if !inBand[i] {
+					inBand[i] = true
+					colors = append(colors, int(i))
+				}
+				t.sixels[i][x] |= 1 << uint(dy)
+			}
+		}
+		for k, i := range colors {
+			if k > 0 {
+				t.w.WriteByte('$')
+			}
+			t.w.WriteByte('#')
+			t.w.WriteString(strconv.Itoa(i))
+			t.writeSixels(t.sixels[i])
+			for x := range t.sixels[i] {
+				t.sixels[i][x] = 0
+			}
+			inBand[i] = false
+		}
+		colors = colors[:0]
+		t.w.WriteByte('-')
+	}
+	t.w.WriteString("\x1b\\")
+}
+
+// writeSixels writes a row of sixels, run-length encoded, leaving out the empty ones at the end.
+func (t *Term) writeSixels(row []byte) {
+	end := len(row)
+	for end > 0 && row[end-1] == 0 {
+		end--
+	}
+	for x := 0; x < end; {
+		n := 1
+		for x+n < end && row[x+n] == row[x] {
+			n++
+		}
+		c := '?' + row[x]
+		if n > 3 {
+			fmt.Fprintf(t.w, "!%d%c", n, c)
+		} else {
+			for i := 0; i < n; i++ {
+				t.w.WriteByte(c)
+			}
+		}
+		x += n
+	}
+}
+
+// kitty transmits the cells as the image with the id and places it at the cells, using the
+// kitty graphics protocol. The RGBA pixels get compressed and sent in chunks.
+func (t *Term) kitty(cells image.Rectangle, id int) {
+	if cells.Empty() {
+		return
+	}
+	r := t.pixels(cells)
+
+	t.zbuf.Reset()
+	zw := zlib.NewWriter(&t.zbuf)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := t.img.PixOffset(r.Min.X, y)
+		zw.Write(t.img.Pix[i : i+4*r.Dx()])
+	}
+	zw.Close()
+	data := base64.StdEncoding.EncodeToString(t.zbuf.Bytes())
+
+	t.moveTo(cells.Min)
+	const chunk = 4096
+	for i := 0; i < len(data); i += chunk {
+		end, more := i+chunk, 1
+		if end >= len(data) {
+			end, more = len(data), 0
+		}
+		if i == 0 {
+			// C=1 keeps the cursor in place, q=2 suppresses the replies
+			fmt.Fprintf(t.w, "\x1b_Ga=T,i=%d,f=32,o=z,s=%d,v=%d,C=1,q=2,m=%d;", id, r.Dx(), r.Dy(), more)
+		} else {
+			fmt.Fprintf(t.w, "\x1b_Gm=%d;", more)
+		}
+		t.w.WriteString(data[i:end])
+		t.w.WriteString("\x1b\\")
+	}
+}
@@ -0,0 +1,366 @@
+// Package term implements a gui.Env rendering into a terminal, which lets the programs built
+// on package gui run over SSH on servers without any display.
+//
+// The Env detects whether the terminal supports the kitty graphics protocol or sixel graphics
+// and pushes the changed parts of its image to the terminal as images. Other terminals get the
+// image drawn with Unicode half-block characters in 24-bit colors, two pixels per cell.
+//
+// The Env produces the events of package win: the mouse is reported using the SGR (1006)
+// extended mouse mode and the keys are translated from their escape sequences. Terminals don't
+// report releasing keys, so each key produces a KbDown immediately followed by a KbUp. Ctrl+C
+// produces a WiClose event. Resizing the terminal produces a Resize event.
+package term
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/internal/damage"
+	"github.com/faiface/gui/win"
+)
+
+// Graphics is a way of drawing an image in a terminal.
+type Graphics int
+
+// List of the ways of drawing an image in a terminal.
+const (
+	Detect     Graphics = iota // query the terminal for its support of Kitty and Sixel
+	HalfBlocks                 // Unicode half-block characters, two pixels per cell
+	Sixel                      // sixel graphics, in a 216 color palette
+	Kitty                      // the kitty terminal graphics protocol
+)
+
+func (g Graphics) String() string {
+	switch g {
+	case Detect:
+		return "detect"
+	case HalfBlocks:
+		return "half-blocks"
+	case Sixel:
+		return "sixel"
+	case Kitty:
+		return "kitty"
+	}
+	return fmt.Sprintf("Graphics(%d)", int(g))
+}
+
+// Option is a functional option to New and Open.
+type Option func(*options)
+
+type options struct {
+	graphics Graphics
+	coalesce bool
+	recovery gui.Recovery
+}
+
+// UseGraphics option sets the way of drawing the image in the terminal. The default is Detect.
+// Sixel and Kitty fall back to HalfBlocks if the size of the cells of the terminal can't be
+// found out.
+func UseGraphics(g Graphics) Option {
+	return func(o *options) {
+		o.graphics = g
+	}
+}
+
+// CoalesceEvents option makes the Term merge the consecutive mouse moves, scrolls and resizes of
+// the terminal which haven't been received yet. Terminals report every cell the mouse crosses,
+// which adds up quickly when a component is slow to redraw. See gui.MakeCoalescingEventsChan.
+func CoalesceEvents() Option {
+	return func(o *options) {
+		o.coalesce = true
+	}
+}
+
+// RecoverPanics option makes the Term recover the panics in the draw functions sent to it and
+// report them to errs, see gui.Recovery. Without it, a panic leaves the terminal in the raw mode
+// on the alternate screen.
+func RecoverPanics(errs chan<- error) Option {
+	return func(o *options) {
+		o.recovery = gui.Recovery{Recover: true, Errs: errs}
+	}
+}
+
+// winsize is struct winsize of the TIOCGWINSZ ioctl.
+type winsize struct {
+	Row, Col       uint16
+	XPixel, YPixel uint16
+}
+
+// Term is a gui.Env rendering into a terminal.
+//
+// It draws to an *image.RGBA and writes the changed parts to the terminal. Closing its Draw()
+// channel restores the terminal to its previous state.
+type Term struct {
+	eventsOut <-chan gui.Event
+	eventsIn  chan<- gui.Event
+	draw      chan func(draw.Image) image.Rectangle
+
+	newSize chan image.Point
+	input   chan []byte
+	damage  *damage.Damage
+	finish  chan struct{}
+
+	recovery gui.Recovery
+
+	in, out  *os.File
+	owned    bool // in and out were opened by Open
+	restore  func() error
+	graphics Graphics
+	cell     image.Point // size of a cell in pixels
+	w        *bufio.Writer
+	img      *image.RGBA
+
+	// buffers for encoding the images
+	indices []uint8
+	sixels  [216][]byte
+	zbuf    bytes.Buffer
+}
+
+// Open opens the controlling terminal of the process, /dev/tty, and creates a Term rendering
+// into it. It works even if the standard input or output are redirected.
+func Open(opts ...Option) (*Term, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("term: %v", err)
+	}
+	t, err := newTerm(f, f, true, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// New creates a Term reading the input from in and rendering to out, which are usually both
+// the same terminal, such as os.Stdin and os.Stdout. The terminal is switched to the raw mode
+// and to the alternate screen until the Draw() channel gets closed.
+//
+// Since in is not closed by the Term, the input arriving after closing the Draw() channel may
+// still be consumed by it.
+func New(in, out *os.File, opts ...Option) (*Term, error) {
+	return newTerm(in, out, false, opts)
+}
+
+func newTerm(in, out *os.File, owned bool, opts []Option) (*Term, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	restore, err := makeRaw(in.Fd())
+	if err != nil {
+		return nil, fmt.Errorf("term: %v", err)
+	}
+	ws, err := getSize(out.Fd())
+	if err != nil {
+		restore()
+		return nil, fmt.Errorf("term: %v", err)
+	}
+
+	eventsOut, eventsIn := gui.MakeEventsChan()
+	if o.coalesce {
+		eventsOut, eventsIn = gui.MakeCoalescingEventsChan()
+	}
+
+	t := &Term{
+		eventsOut: eventsOut,
+		eventsIn:  eventsIn,
+		draw:      make(chan func(draw.Image) image.Rectangle),
+		newSize:   make(chan image.Point),
+		input:     make(chan []byte),
+		damage:    damage.New(),
+		finish:    make(chan struct{}),
+		recovery:  o.recovery,
+		in:        in,
+		out:       out,
+		owned:     owned,
+		restore:   restore,
+		w:         bufio.NewWriterSize(out, 64*1024),
+	}
+
+	go t.readLoop()
+
+	var rest []byte
+	t.graphics, t.cell, rest = t.detect(o.graphics, ws)
+	grid := gridSize(ws)
+	t.img = image.NewRGBA(image.Rectangle{Max: t.pixelSize(grid)})
+
+	// alternate screen, hidden cursor, reporting all mouse motion in the SGR format
+	t.w.WriteString("\x1b[?1049h\x1b[?25l\x1b[?1003h\x1b[?1006h")
+	t.clear()
+	t.w.Flush()
+
+	t.eventsIn <- gui.Resize{Rectangle: t.img.Bounds()}
+
+	go t.drawLoop()
+	go t.eventLoop(rest, grid, t.img.Bounds())
+
+	return t, nil
+}
+
+// Events returns the events channel of the Term.
+func (t *Term) Events() <-chan gui.Event { return t.eventsOut }
+
+// Draw returns the draw channel of the Term.
+func (t *Term) Draw() chan<- func(draw.Image) image.Rectangle { return t.draw }
+
+// Frames returns a channel that receives the time of each write of the changes to the
+// terminal. The Term does not block sending to it, so the frames nobody receives get dropped.
+// See anim.Framer.
+func (t *Term) Frames() <-chan time.Time { return t.damage.Frames() }
+
+// Graphics returns the way the Term draws its image, after the detection.
+func (t *Term) Graphics() Graphics { return t.graphics }
+
+// gridSize returns the size of the terminal in cells, assuming 80x24 if it's unknown.
+func gridSize(ws winsize) image.Point {
+	if ws.Col == 0 || ws.Row == 0 {
+		return image.Pt(80, 24)
+	}
+	return image.Pt(int(ws.Col), int(ws.Row))
+}
+
+// pixelSize returns the size of the image for the size of the terminal in cells. With sixel
+// graphics, the bottom line is left out, because drawing into it would scroll the terminal.
+func (t *Term) pixelSize(grid image.Point) image.Point {
+	if t.graphics == Sixel && grid.Y > 1 {
+		grid.Y--
+	}
+	return image.Pt(grid.X*t.cell.X, grid.Y*t.cell.Y)
+}
+
+// clear clears the screen, along with the kitty images.
+func (t *Term) clear() {
+	t.w.WriteString("\x1b[0m\x1b[2J")
+	if t.graphics == Kitty {
+		t.w.WriteString("\x1b_Ga=d,d=A,q=2\x1b\\")
+	}
+}
+
+func (t *Term) readLoop() {
+	defer close(t.input)
+	buf := make([]byte, 4096)
+	for {
+		n, err := t.in.Read(buf)
+		if n > 0 {
+			select {
+			case t.input <- append([]byte(nil), buf[:n]...):
+			case <-t.finish:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (t *Term) eventLoop(rest []byte, grid image.Point, bounds image.Rectangle) {
+	defer close(t.eventsIn)
+
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer stopResize(resize)
+
+	p := &parser{
+		emit:   func(e gui.Event) { t.eventsIn <- e },
+		cell:   t.cell,
+		bounds: bounds,
+	}
+	var escTimeout <-chan time.Time
+	feed := func(b []byte) {
+		escTimeout = nil
+		if p.feed(b) {
+			// wait a moment for the rest of the escape sequence, then take it as typed keys
+			escTimeout = time.After(escDelay)
+		}
+	}
+	feed(rest)
+
+	for {
+		select {
+		case b, ok := <-t.input:
+			if !ok {
+				// the terminal is gone, either by closing the Term or by hanging up
+				select {
+				case <-t.finish:
+				default:
+					t.eventsIn <- win.WiClose{}
+				}
+				return
+			}
+			feed(b)
+
+		case <-escTimeout:
+			escTimeout = nil
+			p.flush()
+
+		case <-resize:
+			ws, err := getSize(t.out.Fd())
+			if err != nil || gridSize(ws) == grid {
+				continue
+			}
+			grid = gridSize(ws)
+			select {
+			case t.newSize <- grid:
+			case <-t.finish:
+				return
+			}
+			p.bounds = image.Rectangle{Max: t.pixelSize(grid)}
+			t.eventsIn <- gui.Resize{Rectangle: p.bounds}
+
+		case <-t.finish:
+			return
+		}
+	}
+}
+
+// escDelay is how long a lone ESC waits to become an escape sequence before it's taken as the
+// Escape key.
+const escDelay = 25 * time.Millisecond
+
+func (t *Term) drawLoop() {
+	t.damage.Add(t.img.Bounds())
+	for {
+		select {
+		case grid := <-t.newSize:
+			img := image.NewRGBA(image.Rectangle{Max: t.pixelSize(grid)})
+			draw.Draw(img, t.img.Bounds(), t.img, image.ZP, draw.Src)
+			t.img = img
+			t.clear()
+			t.damage.Add(img.Bounds())
+
+		case d, ok := <-t.draw:
+			if !ok {
+				close(t.finish)
+				t.close()
+				return
+			}
+			t.damage.Add(t.recovery.Draw(t, d, t.img))
+
+		case <-t.damage.Ready():
+			if r := t.damage.Take(t.img.Bounds()); !r.Empty() {
+				t.render(r)
+				t.w.Flush()
+				t.damage.Flushed()
+			}
+		}
+	}
+}
+
+// close restores the terminal.
+func (t *Term) close() {
+	t.clear()
+	t.w.WriteString("\x1b[?1006l\x1b[?1003l\x1b[?25h\x1b[?1049l")
+	t.w.Flush()
+	t.restore()
+	if t.owned {
+		t.in.Close()
+	}
+}
@@ -0,0 +1,143 @@
+package term
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/faiface/gui"
+)
+
+// pty is a pseudoterminal, recording everything written to its terminal side.
+type pty struct {
+	master, slave *os.File
+
+	mu  sync.Mutex
+	out bytes.Buffer
+}
+
+func openPty(t *testing.T, ws winsize) *pty {
+	t.Helper()
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pseudoterminals: %v", err)
+	}
+	var n, unlock uint32
+	if err := ioctl(m.Fd(), syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioctl(m.Fd(), syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &pty{master: m, slave: s}
+	p.setSize(t, ws)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := m.Read(buf)
+			p.mu.Lock()
+			p.out.Write(buf[:n])
+			p.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		s.Close()
+		m.Close()
+	})
+	return p
+}
+
+func (p *pty) setSize(t *testing.T, ws winsize) {
+	t.Helper()
+	if err := ioctl(p.master.Fd(), syscall.TIOCSWINSZ, unsafe.Pointer(&ws)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitOutput waits until the terminal receives the output.
+func (p *pty) waitOutput(t *testing.T, want string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		ok := strings.Contains(p.out.String(), want)
+		p.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.Fatalf("the terminal did not receive %q, got %q", want, p.out.String())
+}
+
+// expect receives the events from the Env and compares them with want, "closed" standing for the
+// Events() channel getting closed.
+func expect(t *testing.T, env gui.Env, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		got := "closed"
+		select {
+		case e, ok := <-env.Events():
+			if ok {
+				got = e.String()
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no event within 3s, want %s", w)
+		}
+		if got != w {
+			t.Fatalf("got %s, want %s", got, w)
+		}
+	}
+}
+
+func TestNewHalfBlocks(t *testing.T) {
+	p := openPty(t, winsize{Row: 10, Col: 20})
+	env, err := New(p.slave, p.slave, UseGraphics(HalfBlocks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a cell is two pixels tall with half blocks
+	expect(t, env, "resize/0/0/20/20")
+	p.waitOutput(t, "\x1b[?1049h") // the alternate screen
+
+	p.master.WriteString("a\x1b[<0;5;3M")
+	expect(t, env, "kb/type/97", "mo/move/4/5", "mo/down/4/5/left")
+	p.master.WriteString("\x1b")
+	expect(t, env, "kb/down/escape", "kb/up/escape") // after escDelay
+
+	env.Draw() <- func(drw draw.Image) image.Rectangle {
+		r := image.Rect(2, 2, 4, 4)
+		draw.Draw(drw, r, &image.Uniform{color.RGBA{255, 0, 0, 255}}, image.ZP, draw.Src)
+		return r
+	}
+	// row 2 and column 3 of the terminal, the upper and the lower halves red
+	p.waitOutput(t, "\x1b[2;3H\x1b[38;2;255;0;0m\x1b[48;2;255;0;0m▀▀")
+
+	p.setSize(t, winsize{Row: 12, Col: 30})
+	syscall.Kill(os.Getpid(), syscall.SIGWINCH)
+	expect(t, env, "resize/0/0/30/24")
+
+	p.master.WriteString("\x03")
+	expect(t, env, "wi/close")
+	close(env.Draw())
+	expect(t, env, "closed")
+	p.waitOutput(t, "\x1b[?1049l")
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package term
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("terminals are only supported on Unix systems")
+
+func makeRaw(fd uintptr) (restore func() error, err error) {
+	return nil, errUnsupported
+}
+
+func getSize(fd uintptr) (winsize, error) {
+	return winsize{}, errUnsupported
+}
+
+func notifyResize(c chan<- os.Signal) {}
+
+func stopResize(c chan<- os.Signal) {}
@@ -0,0 +1,63 @@
+package tween
+
+import "math"
+
+// Easing maps the linear progress of a Segment, from 0 to 1, to the eased progress. The eased
+// progress starts at 0 and ends at 1, but may leave that range in between, like Spring does.
+type Easing func(t float64) float64
+
+// Linear progresses at a constant speed.
+func Linear(t float64) float64 { return t }
+
+// InCubic starts slow and accelerates.
+func InCubic(t float64) float64 { return t * t * t }
+
+// OutCubic starts fast and decelerates.
+func OutCubic(t float64) float64 {
+	t = 1 - t
+	return 1 - t*t*t
+}
+
+// InOutCubic accelerates in the first half and decelerates in the second half.
+func InOutCubic(t float64) float64 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	t = 2 - 2*t
+	return 1 - t*t*t/2
+}
+
+// OutBounce bounces off the end a few times, like a dropped ball.
+func OutBounce(t float64) float64 {
+	const n, d = 7.5625, 2.75
+	switch {
+	case t < 1/d:
+		return n * t * t
+	case t < 2/d:
+		t -= 1.5 / d
+		return n*t*t + 0.75
+	case t < 2.5/d:
+		t -= 2.25 / d
+		return n*t*t + 0.9375
+	default:
+		t -= 2.625 / d
+		return n*t*t + 0.984375
+	}
+}
+
+// InBounce is OutBounce played backwards.
+func InBounce(t float64) float64 { return 1 - OutBounce(1-t) }
+
+// Spring overshoots the end and oscillates around it until it settles, like a damped spring.
+func Spring(t float64) float64 {
+	if t >= 1 {
+		return 1
+	}
+	// the oscillation left at the end gets spread over the whole duration, so that the spring
+	// settles exactly at 1 instead of jumping there
+	return 1 - spring(t) + t*spring(1)
+}
+
+func spring(t float64) float64 {
+	return math.Exp(-6*t) * math.Cos(3*math.Pi*t)
+}
@@ -0,0 +1,49 @@
+package tween
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Float interpolates between a and b: 0 gives a, 1 gives b.
+func Float(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+func lerpInt(a, b int, t float64) int {
+	return int(math.Round(Float(float64(a), float64(b), t)))
+}
+
+// Point interpolates between a and b, rounding to the nearest point.
+func Point(a, b image.Point, t float64) image.Point {
+	return image.Pt(lerpInt(a.X, b.X, t), lerpInt(a.Y, b.Y, t))
+}
+
+// Rect interpolates between the corners of a and b.
+func Rect(a, b image.Rectangle, t float64) image.Rectangle {
+	return image.Rectangle{Min: Point(a.Min, b.Min, t), Max: Point(a.Max, b.Max, t)}
+}
+
+// Color interpolates between a and b in the alpha-premultiplied RGBA space. The components get
+// clamped, so that an Easing overshooting the range from 0 to 1 still gives a valid color.
+func Color(a, b color.Color, t float64) color.Color {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	c := func(x, y uint32) uint16 {
+		return uint16(math.Max(0, math.Min(0xffff, math.Round(Float(float64(x), float64(y), t)))))
+	}
+	clr := color.RGBA64{R: c(ar, br), G: c(ag, bg), B: c(ab, bb), A: c(aa, ba)}
+	// premultiplied components must not exceed the alpha
+	clr.R = min16(clr.R, clr.A)
+	clr.G = min16(clr.G, clr.A)
+	clr.B = min16(clr.B, clr.A)
+	return clr
+}
+
+func min16(a, b uint16) uint16 {
+	if a < b {
+		return a
+	}
+	return b
+}
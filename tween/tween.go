@@ -0,0 +1,193 @@
+// Package tween animates values over time using easing curves.
+//
+// A Tween produces a float64 value changing over time. A component can receive the values from
+// the Values() channel alongside its events:
+//
+//	tw := tween.New(tween.Segment{Duration: time.Second / 4, Ease: tween.OutCubic, From: 0, To: 1})
+//	for {
+//		select {
+//		case v, ok := <-tw.Values():
+//			if !ok {
+//				// the animation finished
+//			}
+//			env.Draw() <- redraw(tween.Color(from, to, v))
+//		case e, ok := <-env.Events():
+//			// ...
+//		}
+//	}
+//
+// Or it can ask for the value at the time of an anim.Tick event using At. The functions Float,
+// Point, Rect and Color turn the value into whatever is being animated.
+package tween
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate is the number of values per second a Tween sends to its Values() channel.
+const Rate = 60
+
+// Segment is a part of a Tween. During Duration, the value goes From To, eased by Ease, or
+// linearly if Ease is nil.
+type Segment struct {
+	Duration time.Duration
+	Ease     Easing
+	From, To float64
+}
+
+// Tween is a value changing over time, following a sequence of Segments. A Tween can be
+// reversed and cancelled while running.
+type Tween struct {
+	segments []Segment
+	total    time.Duration
+	values   chan float64
+	done     chan struct{}
+	wake     chan struct{}
+
+	mu        sync.Mutex
+	origin    time.Time
+	offset    time.Duration // the position at origin
+	dir       time.Duration // 1 when playing forward, -1 when reversed
+	cancelled bool
+}
+
+// New creates a Tween playing the segments one after another and starts it.
+func New(segments ...Segment) *Tween {
+	tw := &Tween{
+		segments: segments,
+		values:   make(chan float64, 1),
+		done:     make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		origin:   time.Now(),
+		dir:      1,
+	}
+	for _, s := range segments {
+		tw.total += s.Duration
+	}
+	go tw.run()
+	return tw
+}
+
+// Values returns a channel producing the current value of the Tween at Rate, skipping the values
+// nobody received in time. The last value is always the value at the end of the Tween, or where
+// it got cancelled, and the channel gets closed afterwards.
+func (tw *Tween) Values() <-chan float64 {
+	return tw.values
+}
+
+// Done returns a channel that gets closed when the Tween finishes or gets cancelled.
+func (tw *Tween) Done() <-chan struct{} {
+	return tw.done
+}
+
+// At returns the value of the Tween at the time t and whether the Tween is finished by then.
+// It is useful for computing the values upon receiving anim.Tick events.
+func (tw *Tween) At(t time.Time) (value float64, finished bool) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	pos, finished := tw.position(t)
+	return tw.valueAt(pos), finished
+}
+
+// Cancel stops the Tween at its current value.
+func (tw *Tween) Cancel() {
+	tw.mu.Lock()
+	if !tw.cancelled {
+		now := time.Now()
+		tw.offset, _ = tw.position(now)
+		tw.origin = now
+		tw.cancelled = true
+	}
+	tw.mu.Unlock()
+	tw.notify()
+}
+
+// Reverse makes the Tween play backwards from its current value towards its beginning, or
+// forwards again if it was reversed already. It has no effect on a finished Tween.
+func (tw *Tween) Reverse() {
+	tw.mu.Lock()
+	now := time.Now()
+	if pos, finished := tw.position(now); !finished {
+		tw.offset, tw.origin = pos, now
+		tw.dir = -tw.dir
+	}
+	tw.mu.Unlock()
+	tw.notify()
+}
+
+func (tw *Tween) notify() {
+	select {
+	case tw.wake <- struct{}{}:
+	default:
+	}
+}
+
+// position must be called with tw.mu locked.
+func (tw *Tween) position(t time.Time) (pos time.Duration, finished bool) {
+	if tw.cancelled {
+		return tw.offset, true
+	}
+	pos = tw.offset + tw.dir*t.Sub(tw.origin)
+	if pos >= tw.total && tw.dir > 0 {
+		return tw.total, true
+	}
+	if pos <= 0 && tw.dir < 0 {
+		return 0, true
+	}
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > tw.total {
+		pos = tw.total
+	}
+	return pos, false
+}
+
+func (tw *Tween) valueAt(pos time.Duration) float64 {
+	if len(tw.segments) == 0 {
+		return 0
+	}
+	for i, s := range tw.segments {
+		if pos >= s.Duration && i < len(tw.segments)-1 {
+			pos -= s.Duration
+			continue
+		}
+		t := 1.0
+		if s.Duration > 0 {
+			t = float64(pos) / float64(s.Duration)
+		}
+		if t > 1 {
+			t = 1
+		}
+		if s.Ease != nil {
+			t = s.Ease(t)
+		}
+		return Float(s.From, s.To, t)
+	}
+	panic("unreachable")
+}
+
+func (tw *Tween) run() {
+	ticker := time.NewTicker(time.Second / Rate)
+	defer ticker.Stop()
+	defer close(tw.done)
+	defer close(tw.values)
+
+	for {
+		v, finished := tw.At(time.Now())
+		// replace the value nobody received yet
+		select {
+		case <-tw.values:
+		default:
+		}
+		tw.values <- v
+		if finished {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-tw.wake:
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package tween_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui/tween"
+)
+
+var easings = []struct {
+	name      string
+	ease      tween.Easing
+	monotonic bool
+}{
+	{"Linear", tween.Linear, true},
+	{"InCubic", tween.InCubic, true},
+	{"OutCubic", tween.OutCubic, true},
+	{"InOutCubic", tween.InOutCubic, true},
+	{"OutBounce", tween.OutBounce, false},
+	{"InBounce", tween.InBounce, false},
+	{"Spring", tween.Spring, false},
+}
+
+func TestEasingEnds(t *testing.T) {
+	const eps = 1e-9
+	for _, e := range easings {
+		if got := e.ease(0); math.Abs(got) > eps {
+			t.Errorf("%s(0) = %v, want 0", e.name, got)
+		}
+		if got := e.ease(1); math.Abs(got-1) > eps {
+			t.Errorf("%s(1) = %v, want 1", e.name, got)
+		}
+		// no jumps at the ends
+		if got := e.ease(1e-9); math.Abs(got) > 1e-6 {
+			t.Errorf("%s(1e-9) = %v, want about 0", e.name, got)
+		}
+		if got := e.ease(1 - 1e-9); math.Abs(got-1) > 1e-6 {
+			t.Errorf("%s(1-1e-9) = %v, want about 1", e.name, got)
+		}
+	}
+}
+
+func TestEasingShape(t *testing.T) {
+	const n = 10000
+	for _, e := range easings {
+		prev, max := e.ease(0), e.ease(0)
+		for i := 1; i <= n; i++ {
+			v := e.ease(float64(i) / n)
+			if e.monotonic && v < prev {
+				t.Errorf("%s decreases from %v to %v at %v", e.name, prev, v, float64(i)/n)
+				break
+			}
+			if math.Abs(v-prev) > 0.01 {
+				t.Errorf("%s jumps from %v to %v at %v", e.name, prev, v, float64(i)/n)
+				break
+			}
+			prev, max = v, math.Max(max, v)
+		}
+		switch {
+		case e.name == "Spring" && max <= 1:
+			t.Errorf("Spring doesn't overshoot, max %v", max)
+		case e.name != "Spring" && max > 1+1e-9:
+			t.Errorf("%s overshoots to %v", e.name, max)
+		}
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct{ a, b, t, want float64 }{
+		{2, 4, 0, 2},
+		{2, 4, 1, 4},
+		{2, 4, 0.5, 3},
+		{4, 2, 0.25, 3.5},
+		{0, 10, 1.5, 15}, // an overshooting Easing
+		{0, 10, -0.5, -5},
+	}
+	for _, test := range tests {
+		if got := tween.Float(test.a, test.b, test.t); got != test.want {
+			t.Errorf("Float(%v, %v, %v) = %v, want %v", test.a, test.b, test.t, got, test.want)
+		}
+	}
+}
+
+func TestPointRect(t *testing.T) {
+	a, b := image.Pt(0, 10), image.Pt(3, -10)
+	if got := tween.Point(a, b, 0); got != a {
+		t.Errorf("Point at 0 = %v, want %v", got, a)
+	}
+	if got := tween.Point(a, b, 1); got != b {
+		t.Errorf("Point at 1 = %v, want %v", got, b)
+	}
+	if got, want := tween.Point(a, b, 0.5), image.Pt(2, 0); got != want {
+		t.Errorf("Point at 0.5 = %v, want %v rounded", got, want)
+	}
+
+	ra, rb := image.Rect(0, 0, 10, 10), image.Rect(10, 20, 30, 40)
+	if got, want := tween.Rect(ra, rb, 0.5), image.Rect(5, 10, 20, 25); got != want {
+		t.Errorf("Rect at 0.5 = %v, want %v", got, want)
+	}
+	if got := tween.Rect(ra, rb, 1); got != rb {
+		t.Errorf("Rect at 1 = %v, want %v", got, rb)
+	}
+}
+
+func TestColor(t *testing.T) {
+	black, white := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+	rgba64 := func(c color.Color) color.RGBA64 {
+		return color.RGBA64Model.Convert(c).(color.RGBA64)
+	}
+	if got := rgba64(tween.Color(black, white, 0)); got != rgba64(black) {
+		t.Errorf("Color at 0 = %v, want %v", got, black)
+	}
+	if got := rgba64(tween.Color(black, white, 1)); got != rgba64(white) {
+		t.Errorf("Color at 1 = %v, want %v", got, white)
+	}
+	if got, want := rgba64(tween.Color(black, white, 0.5)), (color.RGBA64{0x8000, 0x8000, 0x8000, 0xffff}); got != want {
+		t.Errorf("Color at 0.5 = %v, want %v", got, want)
+	}
+	if got := rgba64(tween.Color(black, white, 1.5)); got != rgba64(white) {
+		t.Errorf("Color at 1.5 = %v, want it clamped to %v", got, white)
+	}
+
+	// the premultiplied components never exceed the alpha, even when overshooting
+	transparent, red := color.RGBA{}, color.RGBA{255, 0, 0, 255}
+	for _, at := range []float64{-0.5, 0, 0.3, 1, 1.5} {
+		got := rgba64(tween.Color(red, transparent, at))
+		if got.R > got.A {
+			t.Errorf("Color at %v = %v, not alpha-premultiplied", at, got)
+		}
+	}
+}
+
+func TestTween(t *testing.T) {
+	tw := tween.New(
+		tween.Segment{Duration: 20 * time.Millisecond, From: 0, To: 1},
+		tween.Segment{Duration: 20 * time.Millisecond, Ease: tween.Spring, From: 1, To: 3},
+	)
+	start := time.Now()
+	if v, finished := tw.At(start.Add(-time.Hour)); v != 0 || finished {
+		t.Errorf("At before the start = %v, %v, want 0, false", v, finished)
+	}
+	if v, finished := tw.At(start.Add(time.Hour)); v != 3 || !finished {
+		t.Errorf("At after the end = %v, %v, want 3, true", v, finished)
+	}
+
+	var last float64
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case v, ok := <-tw.Values():
+			if !ok {
+				if last != 3 {
+					t.Errorf("the last value is %v, want 3", last)
+				}
+				<-tw.Done()
+				return
+			}
+			last = v
+		case <-timeout:
+			t.Fatal("the Tween did not finish within 1s")
+		}
+	}
+}
+
+func TestTweenCancel(t *testing.T) {
+	tw := tween.New(tween.Segment{Duration: time.Hour, From: 0, To: 1})
+	tw.Cancel()
+	var last float64
+	for v := range tw.Values() {
+		last = v
+	}
+	if last >= 0.01 {
+		t.Errorf("the last value is %v, want where the Tween got cancelled", last)
+	}
+	if v, finished := tw.At(time.Now().Add(time.Hour)); v != last || !finished {
+		t.Errorf("At after cancelling = %v, %v, want %v, true", v, finished, last)
+	}
+}
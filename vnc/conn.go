@@ -0,0 +1,440 @@
+package vnc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/faiface/gui/win"
+)
+
+// RFB message types and encodings
+const (
+	msgSetPixelFormat           = 0
+	msgSetEncodings             = 2
+	msgFramebufferUpdateRequest = 3
+	msgKeyEvent                 = 4
+	msgPointerEvent             = 5
+	msgClientCutText            = 6
+
+	msgFramebufferUpdate = 0
+
+	encRaw  = 0
+	encZlib = 6
+
+	secNone = 1
+	secVNC  = 2
+)
+
+// client is a connection of a VNC client.
+type client struct {
+	s    *Server
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	wake chan struct{}
+	done chan struct{}
+
+	mu        sync.Mutex
+	format    pixelFormat
+	zlib      bool
+	requested bool
+	damaged   image.Rectangle
+
+	// input state, only used by the reading goroutine
+	buttons byte
+	pos     image.Point
+	pressed map[uint32]bool
+	ctrl    int
+}
+
+func newClient(s *Server, conn net.Conn) *client {
+	return &client{
+		s:       s,
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		w:       bufio.NewWriter(conn),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		format:  serverFormat,
+		pos:     image.Pt(-1, -1),
+		pressed: make(map[uint32]bool),
+	}
+}
+
+// damage marks the rectangle of the image as changed.
+func (c *client) damage(r image.Rectangle) {
+	c.mu.Lock()
+	c.damaged = c.damaged.Union(r)
+	c.mu.Unlock()
+	c.notify()
+}
+
+func (c *client) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// serve talks to the client until it disconnects or the Server gets closed.
+func (c *client) serve() {
+	defer c.conn.Close()
+	if err := c.handshake(); err != nil {
+		return
+	}
+
+	go c.writeLoop()
+	defer close(c.done)
+
+	c.readLoop()
+	c.release()
+}
+
+// release releases the buttons and the keys held by the disconnected client.
+func (c *client) release() {
+	c.pointer(0, c.pos)
+	for sym := range c.pressed {
+		c.key(false, sym)
+	}
+}
+
+func (c *client) handshake() error {
+	if _, err := c.w.WriteString("RFB 003.008\n"); err != nil {
+		return err
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	var version [12]byte
+	if _, err := io.ReadFull(c.r, version[:]); err != nil {
+		return err
+	}
+	minor, err := strconv.Atoi(string(version[8:11]))
+	if err != nil || !bytes.HasPrefix(version[:], []byte("RFB 003.")) || version[11] != '\n' {
+		return fmt.Errorf("vnc: unsupported protocol version %q", version)
+	}
+	if minor > 8 {
+		minor = 8
+	}
+
+	security := byte(secNone)
+	if c.s.password != "" {
+		security = secVNC
+	}
+
+	// security type: chosen by the server in 3.3, by the client since 3.7
+	if minor < 7 {
+		binary.Write(c.w, binary.BigEndian, uint32(security))
+	} else {
+		c.w.Write([]byte{1, security})
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	if minor >= 7 {
+		chosen, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if chosen != security {
+			return c.securityFailed(minor, "unsupported security type")
+		}
+	}
+
+	switch security {
+	case secNone:
+		if minor >= 8 {
+			binary.Write(c.w, binary.BigEndian, uint32(0))
+		}
+	case secVNC:
+		var challenge, response [16]byte
+		if _, err := rand.Read(challenge[:]); err != nil {
+			return err
+		}
+		c.w.Write(challenge[:])
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(c.r, response[:]); err != nil {
+			return err
+		}
+		expected := vncAuth(c.s.password, challenge)
+		if subtle.ConstantTimeCompare(response[:], expected[:]) != 1 {
+			return c.securityFailed(minor, "authentication failed")
+		}
+		binary.Write(c.w, binary.BigEndian, uint32(0))
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	// ClientInit, the shared flag is ignored since all the clients share the image
+	if _, err := c.r.ReadByte(); err != nil {
+		return err
+	}
+
+	size := c.s.img.Bounds().Size()
+	binary.Write(c.w, binary.BigEndian, uint16(size.X))
+	binary.Write(c.w, binary.BigEndian, uint16(size.Y))
+	c.w.Write(serverFormat.marshal())
+	binary.Write(c.w, binary.BigEndian, uint32(len(c.s.name)))
+	c.w.WriteString(c.s.name)
+	return c.w.Flush()
+}
+
+// securityFailed sends the failed SecurityResult and returns the reason as an error.
+func (c *client) securityFailed(minor int, reason string) error {
+	binary.Write(c.w, binary.BigEndian, uint32(1))
+	if minor >= 8 {
+		binary.Write(c.w, binary.BigEndian, uint32(len(reason)))
+		c.w.WriteString(reason)
+	}
+	c.w.Flush()
+	return errors.New("vnc: " + reason)
+}
+
+// vncAuth encrypts the challenge with the password, using DES with the bits of each byte of the
+// key reversed, like every VNC implementation does.
+func vncAuth(password string, challenge [16]byte) [16]byte {
+	var key [8]byte
+	copy(key[:], password)
+	for i, b := range key {
+		var r byte
+		for j := 0; j < 8; j++ {
+			r |= (b >> uint(j) & 1) << uint(7-j)
+		}
+		key[i] = r
+	}
+	cipher, _ := des.NewCipher(key[:]) // never fails with a key of 8 bytes
+	var response [16]byte
+	cipher.Encrypt(response[:8], challenge[:8])
+	cipher.Encrypt(response[8:], challenge[8:])
+	return response
+}
+
+func (c *client) readLoop() {
+	var buf [20]byte
+	for {
+		msgType, err := c.r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		switch msgType {
+		case msgSetPixelFormat:
+			if _, err := io.ReadFull(c.r, buf[:19]); err != nil {
+				return
+			}
+			format, err := unmarshalPixelFormat(buf[3:19])
+			if err != nil {
+				return
+			}
+			c.mu.Lock()
+			c.format = format
+			c.mu.Unlock()
+
+		case msgSetEncodings:
+			if _, err := io.ReadFull(c.r, buf[:3]); err != nil {
+				return
+			}
+			n := int(binary.BigEndian.Uint16(buf[1:]))
+			useZlib := false
+			for i := 0; i < n; i++ {
+				if _, err := io.ReadFull(c.r, buf[:4]); err != nil {
+					return
+				}
+				if int32(binary.BigEndian.Uint32(buf[:])) == encZlib {
+					useZlib = true
+				}
+			}
+			c.mu.Lock()
+			c.zlib = useZlib
+			c.mu.Unlock()
+
+		case msgFramebufferUpdateRequest:
+			if _, err := io.ReadFull(c.r, buf[:9]); err != nil {
+				return
+			}
+			incremental := buf[0] != 0
+			x, y := int(binary.BigEndian.Uint16(buf[1:])), int(binary.BigEndian.Uint16(buf[3:]))
+			w, h := int(binary.BigEndian.Uint16(buf[5:])), int(binary.BigEndian.Uint16(buf[7:]))
+			c.mu.Lock()
+			c.requested = true
+			if !incremental {
+				c.damaged = c.damaged.Union(image.Rect(x, y, x+w, y+h))
+			}
+			c.mu.Unlock()
+			c.notify()
+
+		case msgKeyEvent:
+			if _, err := io.ReadFull(c.r, buf[:7]); err != nil {
+				return
+			}
+			c.key(buf[0] != 0, binary.BigEndian.Uint32(buf[3:]))
+
+		case msgPointerEvent:
+			if _, err := io.ReadFull(c.r, buf[:5]); err != nil {
+				return
+			}
+			pt := image.Pt(int(binary.BigEndian.Uint16(buf[1:])), int(binary.BigEndian.Uint16(buf[3:])))
+			c.pointer(buf[0], pt)
+
+		case msgClientCutText:
+			if _, err := io.ReadFull(c.r, buf[:7]); err != nil {
+				return
+			}
+			n := int(binary.BigEndian.Uint32(buf[3:]))
+			if _, err := c.r.Discard(n); err != nil {
+				return
+			}
+
+		default:
+			return // unknown message, its length is unknown too
+		}
+	}
+}
+
+// buttons of the pointer button mask
+var buttons = []win.Button{win.ButtonLeft, win.ButtonMiddle, win.ButtonRight}
+
+// scrolls are the amounts scrolled by the buttons of the mask used for the mouse wheel, the same
+// as those of the X server
+var scrolls = map[byte]image.Point{
+	1 << 3: {0, 1},
+	1 << 4: {0, -1},
+	1 << 5: {1, 0},
+	1 << 6: {-1, 0},
+}
+
+func (c *client) pointer(mask byte, pt image.Point) {
+	events := c.s.eventsIn
+	if pt != c.pos {
+		c.pos = pt
+		events <- win.MoMove{Point: pt}
+	}
+	for i, btn := range buttons {
+		bit := byte(1) << uint(i)
+		switch {
+		case mask&bit != 0 && c.buttons&bit == 0:
+			events <- win.MoDown{Point: pt, Button: btn}
+		case mask&bit == 0 && c.buttons&bit != 0:
+			events <- win.MoUp{Point: pt, Button: btn}
+		}
+	}
+	for bit := byte(1 << 3); bit < 1<<7; bit <<= 1 {
+		if mask&bit != 0 && c.buttons&bit == 0 {
+			events <- win.MoScroll{Point: scrolls[bit]}
+		}
+	}
+	c.buttons = mask
+}
+
+func (c *client) key(down bool, sym uint32) {
+	events := c.s.eventsIn
+	k, isKey := keys[sym]
+
+	if !down {
+		if !c.pressed[sym] {
+			return
+		}
+		delete(c.pressed, sym)
+		if k == win.KeyCtrl {
+			c.ctrl--
+		}
+		if isKey {
+			events <- win.KbUp{Key: k}
+		}
+		return
+	}
+
+	// the clients repeat a held key by sending it as pressed again
+	repeat := c.pressed[sym]
+	c.pressed[sym] = true
+	if k == win.KeyCtrl && !repeat {
+		c.ctrl++
+	}
+	if isKey {
+		if repeat {
+			events <- win.KbRepeat{Key: k}
+		} else {
+			events <- win.KbDown{Key: k}
+		}
+	}
+	if r, ok := keysymRune(sym); ok && c.ctrl == 0 {
+		events <- win.KbType{Rune: r}
+	}
+}
+
+// writeLoop sends the changed parts of the image whenever the client requests them.
+func (c *client) writeLoop() {
+	var (
+		raw  []byte
+		zbuf bytes.Buffer
+		zw   *zlib.Writer
+	)
+	for {
+		select {
+		case <-c.wake:
+		case <-c.done:
+			return
+		}
+
+		c.mu.Lock()
+		r := c.damaged.Intersect(c.s.img.Bounds())
+		if !c.requested || r.Empty() {
+			c.mu.Unlock()
+			continue
+		}
+		c.requested, c.damaged = false, image.ZR
+		format, useZlib := c.format, c.zlib
+		c.mu.Unlock()
+
+		// encode under the lock, but send without it, so that slow clients don't hold the drawing
+		c.s.imgMu.RLock()
+		raw = format.encode(raw[:0], c.s.img, r)
+		c.s.imgMu.RUnlock()
+
+		var header [16]byte
+		header[0] = msgFramebufferUpdate
+		binary.BigEndian.PutUint16(header[2:], 1)
+		binary.BigEndian.PutUint16(header[4:], uint16(r.Min.X))
+		binary.BigEndian.PutUint16(header[6:], uint16(r.Min.Y))
+		binary.BigEndian.PutUint16(header[8:], uint16(r.Dx()))
+		binary.BigEndian.PutUint16(header[10:], uint16(r.Dy()))
+
+		if useZlib {
+			// a single zlib stream is used for the whole connection
+			zbuf.Reset()
+			if zw == nil {
+				zw = zlib.NewWriter(&zbuf)
+			}
+			zw.Write(raw)
+			zw.Flush()
+			binary.BigEndian.PutUint32(header[12:], encZlib)
+			c.w.Write(header[:])
+			binary.Write(c.w, binary.BigEndian, uint32(zbuf.Len()))
+			c.w.Write(zbuf.Bytes())
+		} else {
+			binary.BigEndian.PutUint32(header[12:], encRaw)
+			c.w.Write(header[:])
+			c.w.Write(raw)
+		}
+		if err := c.w.Flush(); err != nil {
+			c.conn.Close()
+			return
+		}
+	}
+}
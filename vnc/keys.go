@@ -0,0 +1,40 @@
+package vnc
+
+import "github.com/faiface/gui/win"
+
+// keys maps the keysyms sent by the clients to the keys of package win.
+var keys = map[uint32]win.Key{
+	0xff08: win.KeyBackspace,
+	0xff09: win.KeyTab,
+	0xff0d: win.KeyEnter,
+	0xff1b: win.KeyEscape,
+	0xff50: win.KeyHome,
+	0xff51: win.KeyLeft,
+	0xff52: win.KeyUp,
+	0xff53: win.KeyRight,
+	0xff54: win.KeyDown,
+	0xff55: win.KeyPageUp,
+	0xff56: win.KeyPageDown,
+	0xff57: win.KeyEnd,
+	0xff8d: win.KeyEnter, // keypad
+	0xffe1: win.KeyShift,
+	0xffe2: win.KeyShift,
+	0xffe3: win.KeyCtrl,
+	0xffe4: win.KeyCtrl,
+	0xffe9: win.KeyAlt,
+	0xffea: win.KeyAlt,
+	0xffff: win.KeyDelete,
+	0x0020: win.KeySpace,
+}
+
+// keysymRune returns the character of a keysym. The clients send the keysyms of the characters
+// actually typed, so Shift is already taken into account.
+func keysymRune(sym uint32) (rune, bool) {
+	switch {
+	case sym >= 0x20 && sym <= 0x7e, sym >= 0xa0 && sym <= 0xff:
+		return rune(sym), true // Latin-1
+	case sym&0xff000000 == 0x01000000:
+		return rune(sym & 0x00ffffff), true // Unicode
+	}
+	return 0, false
+}
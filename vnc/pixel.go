@@ -0,0 +1,96 @@
+package vnc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// pixelFormat is the PIXEL_FORMAT structure, describing how a client wants the pixels encoded.
+type pixelFormat struct {
+	BPP, Depth           uint8
+	BigEndian, TrueColor bool
+	RedMax, GreenMax     uint16
+	BlueMax              uint16
+	RedShift, GreenShift uint8
+	BlueShift            uint8
+}
+
+// serverFormat is the format announced in ServerInit: 32 bits per pixel, stored as blue, green,
+// red and an unused byte.
+var serverFormat = pixelFormat{
+	BPP: 32, Depth: 24,
+	TrueColor: true,
+	RedMax:    255, GreenMax: 255, BlueMax: 255,
+	RedShift: 16, GreenShift: 8, BlueShift: 0,
+}
+
+func (pf pixelFormat) marshal() []byte {
+	b := make([]byte, 16)
+	b[0], b[1] = pf.BPP, pf.Depth
+	if pf.BigEndian {
+		b[2] = 1
+	}
+	if pf.TrueColor {
+		b[3] = 1
+	}
+	binary.BigEndian.PutUint16(b[4:], pf.RedMax)
+	binary.BigEndian.PutUint16(b[6:], pf.GreenMax)
+	binary.BigEndian.PutUint16(b[8:], pf.BlueMax)
+	b[10], b[11], b[12] = pf.RedShift, pf.GreenShift, pf.BlueShift
+	return b
+}
+
+func unmarshalPixelFormat(b []byte) (pixelFormat, error) {
+	pf := pixelFormat{
+		BPP:        b[0],
+		Depth:      b[1],
+		BigEndian:  b[2] != 0,
+		TrueColor:  b[3] != 0,
+		RedMax:     binary.BigEndian.Uint16(b[4:]),
+		GreenMax:   binary.BigEndian.Uint16(b[6:]),
+		BlueMax:    binary.BigEndian.Uint16(b[8:]),
+		RedShift:   b[10],
+		GreenShift: b[11],
+		BlueShift:  b[12],
+	}
+	if pf.BPP != 8 && pf.BPP != 16 && pf.BPP != 32 {
+		return pf, fmt.Errorf("unsupported pixel format: %d bits per pixel", pf.BPP)
+	}
+	if !pf.TrueColor {
+		return pf, fmt.Errorf("unsupported pixel format: color map")
+	}
+	return pf, nil
+}
+
+// encode converts the rectangle r of img to the pixel format, appending to dst.
+func (pf pixelFormat) encode(dst []byte, img *image.RGBA, r image.Rectangle) []byte {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		row := img.Pix[i : i+4*r.Dx()]
+		if pf == serverFormat {
+			for x := 0; x < len(row); x += 4 {
+				dst = append(dst, row[x+2], row[x+1], row[x], 0)
+			}
+			continue
+		}
+		for x := 0; x < len(row); x += 4 {
+			p := uint32(row[x])*uint32(pf.RedMax)/255<<pf.RedShift |
+				uint32(row[x+1])*uint32(pf.GreenMax)/255<<pf.GreenShift |
+				uint32(row[x+2])*uint32(pf.BlueMax)/255<<pf.BlueShift
+			switch {
+			case pf.BPP == 8:
+				dst = append(dst, byte(p))
+			case pf.BPP == 16 && pf.BigEndian:
+				dst = append(dst, byte(p>>8), byte(p))
+			case pf.BPP == 16:
+				dst = append(dst, byte(p), byte(p>>8))
+			case pf.BigEndian:
+				dst = append(dst, byte(p>>24), byte(p>>16), byte(p>>8), byte(p))
+			default:
+				dst = append(dst, byte(p), byte(p>>8), byte(p>>16), byte(p>>24))
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,238 @@
+// Package vnc implements a gui.Env served over the RFB protocol, version 3.8, so that it can be
+// viewed and operated remotely with any VNC client.
+//
+// Any number of clients can connect at the same time. They all see the same image and their
+// input gets merged into the events of the Env: the pointer produces MoMove, MoDown, MoUp and
+// MoScroll events and the keyboard produces KbDown, KbUp, KbRepeat and KbType events, just like
+// the windows of package win.
+//
+// The changed parts of the image are sent using the Raw encoding, or the Zlib encoding to the
+// clients supporting it.
+package vnc
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/internal/damage"
+)
+
+// Option is a functional option to New and Listen.
+type Option func(*options)
+
+type options struct {
+	width, height int
+	name          string
+	password      string
+	coalesce      bool
+	recovery      gui.Recovery
+}
+
+// Size option sets the width and height of the framebuffer.
+func Size(width, height int) Option {
+	return func(o *options) {
+		o.width = width
+		o.height = height
+	}
+}
+
+// Name option sets the name of the desktop shown by the clients.
+func Name(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// Password option makes the clients authenticate using the VNC authentication. Only the first
+// eight characters of the password are used, as in every VNC server.
+//
+// The VNC authentication doesn't encrypt the connection and is weak by today's standards. Use
+// it on trusted networks, or through a tunnel, such as SSH.
+func Password(password string) Option {
+	return func(o *options) {
+		o.password = password
+	}
+}
+
+// CoalesceEvents option makes the Server merge the consecutive pointer moves and scrolls of the
+// clients which haven't been received yet, so that a slow component doesn't fall behind a client
+// sending them at a high rate. See gui.MakeCoalescingEventsChan.
+func CoalesceEvents() Option {
+	return func(o *options) {
+		o.coalesce = true
+	}
+}
+
+// RecoverPanics option makes the Server recover the panics in the draw functions sent to it and
+// report them to errs, see gui.Recovery. The clients keep being served.
+func RecoverPanics(errs chan<- error) Option {
+	return func(o *options) {
+		o.recovery = gui.Recovery{Recover: true, Errs: errs}
+	}
+}
+
+// Server is a gui.Env serving its image to VNC clients.
+//
+// It draws to an *image.RGBA and sends its changed parts to the clients, as they request them.
+// Closing its Draw() channel closes the listener and disconnects all the clients.
+type Server struct {
+	eventsOut <-chan gui.Event
+	eventsIn  chan<- gui.Event
+	draw      chan func(draw.Image) image.Rectangle
+	damage    *damage.Damage
+	finish    chan struct{}
+
+	recovery gui.Recovery
+
+	l        net.Listener
+	name     string
+	password string
+
+	imgMu sync.RWMutex // draw functions write to img, clients read it
+	img   *image.RGBA
+
+	clientsMu sync.Mutex
+	clients   map[*client]bool
+	wg        sync.WaitGroup // the accept loop and the clients
+}
+
+// Listen listens on the TCP network address, such as ":5900", and creates a Server accepting
+// the clients on it.
+func Listen(addr string, opts ...Option) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("vnc: %v", err)
+	}
+	return New(l, opts...), nil
+}
+
+// New creates a Server accepting the clients on the listener.
+//
+// The default size is 640x480 and the default name is empty. Without the Password option, the
+// clients connect without any authentication.
+func New(l net.Listener, opts ...Option) *Server {
+	o := options{
+		width:  640,
+		height: 480,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	eventsOut, eventsIn := gui.MakeEventsChan()
+	if o.coalesce {
+		eventsOut, eventsIn = gui.MakeCoalescingEventsChan()
+	}
+
+	s := &Server{
+		eventsOut: eventsOut,
+		eventsIn:  eventsIn,
+		draw:      make(chan func(draw.Image) image.Rectangle),
+		damage:    damage.New(),
+		finish:    make(chan struct{}),
+		recovery:  o.recovery,
+		l:         l,
+		name:      o.name,
+		password:  o.password,
+		img:       image.NewRGBA(image.Rect(0, 0, o.width, o.height)),
+		clients:   make(map[*client]bool),
+	}
+
+	s.eventsIn <- gui.Resize{Rectangle: s.img.Bounds()}
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+	go s.drawLoop()
+
+	return s
+}
+
+// Events returns the events channel of the Server.
+func (s *Server) Events() <-chan gui.Event { return s.eventsOut }
+
+// Draw returns the draw channel of the Server.
+func (s *Server) Draw() chan<- func(draw.Image) image.Rectangle { return s.draw }
+
+// Frames returns a channel that receives the time of each batch of changes made available to
+// the clients. The Server does not block sending to it, so the frames nobody receives get
+// dropped. See anim.Framer.
+func (s *Server) Frames() <-chan time.Time { return s.damage.Frames() }
+
+// Addr returns the address the Server listens on.
+func (s *Server) Addr() net.Addr { return s.l.Addr() }
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return // the listener got closed
+		}
+
+		c := newClient(s, conn)
+		s.clientsMu.Lock()
+		select {
+		case <-s.finish:
+			s.clientsMu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.clients[c] = true
+		s.wg.Add(1)
+		s.clientsMu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+			s.clientsMu.Lock()
+			delete(s.clients, c)
+			s.clientsMu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) drawLoop() {
+	for {
+		select {
+		case d, ok := <-s.draw:
+			if !ok {
+				s.close()
+				return
+			}
+			s.imgMu.Lock()
+			s.damage.Add(s.recovery.Draw(s, d, s.img))
+			s.imgMu.Unlock()
+
+		case <-s.damage.Ready():
+			// hand the changes over to the clients
+			if r := s.damage.Take(s.img.Bounds()); !r.Empty() {
+				s.clientsMu.Lock()
+				for c := range s.clients {
+					c.damage(r)
+				}
+				s.clientsMu.Unlock()
+				s.damage.Flushed()
+			}
+		}
+	}
+}
+
+// close stops accepting the clients, disconnects them and closes the Events() channel once
+// none of them can produce any more events.
+func (s *Server) close() {
+	s.clientsMu.Lock()
+	close(s.finish)
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.clientsMu.Unlock()
+	s.l.Close()
+	s.wg.Wait()
+	close(s.eventsIn)
+}
@@ -0,0 +1,301 @@
+package vnc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+// rfbClient is the client side of an RFB 3.8 connection.
+type rfbClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+
+	zr   io.ReadCloser // one zlib stream for the whole connection
+	zsrc bytes.Buffer
+
+	size image.Point
+	name string
+}
+
+func (c *rfbClient) read(n int) []byte {
+	c.t.Helper()
+	b := make([]byte, n)
+	c.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		c.t.Fatal(err)
+	}
+	return b
+}
+
+func (c *rfbClient) write(b ...byte) {
+	c.t.Helper()
+	if _, err := c.conn.Write(b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// dial connects to the Server and performs the RFB 3.8 handshake. It returns the reason if the
+// Server refuses the client.
+func dial(t *testing.T, s *Server, password string) (c *rfbClient, refused string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c = &rfbClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	if version := string(c.read(12)); version != "RFB 003.008\n" {
+		t.Fatalf("server version %q", version)
+	}
+	c.write([]byte("RFB 003.008\n")...)
+
+	want := byte(secNone)
+	if password != "" {
+		want = secVNC
+	}
+	if types := c.read(2); types[0] != 1 || types[1] != want {
+		t.Fatalf("security types %v, want [1 %d]", types, want)
+	}
+	c.write(want)
+	if want == secVNC {
+		var challenge [16]byte
+		copy(challenge[:], c.read(16))
+		response := vncAuth(password, challenge)
+		c.write(response[:]...)
+	}
+	if result := binary.BigEndian.Uint32(c.read(4)); result != 0 {
+		return nil, string(c.read(int(binary.BigEndian.Uint32(c.read(4)))))
+	}
+
+	c.write(1) // shared
+	init := c.read(24)
+	c.size = image.Pt(int(binary.BigEndian.Uint16(init[0:])), int(binary.BigEndian.Uint16(init[2:])))
+	if pf, _ := unmarshalPixelFormat(init[4:20]); pf != serverFormat {
+		t.Errorf("server pixel format %+v", pf)
+	}
+	c.name = string(c.read(int(binary.BigEndian.Uint32(init[20:]))))
+	return c, ""
+}
+
+func (c *rfbClient) request(incremental bool, r image.Rectangle) {
+	c.t.Helper()
+	b := []byte{msgFramebufferUpdateRequest, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if incremental {
+		b[1] = 1
+	}
+	binary.BigEndian.PutUint16(b[2:], uint16(r.Min.X))
+	binary.BigEndian.PutUint16(b[4:], uint16(r.Min.Y))
+	binary.BigEndian.PutUint16(b[6:], uint16(r.Dx()))
+	binary.BigEndian.PutUint16(b[8:], uint16(r.Dy()))
+	c.write(b...)
+}
+
+// update reads a FramebufferUpdate of one rectangle and returns the rectangle, its encoding and
+// its decoded pixels, 4 bytes each.
+func (c *rfbClient) update() (image.Rectangle, int32, []byte) {
+	c.t.Helper()
+	if h := c.read(4); h[0] != msgFramebufferUpdate || binary.BigEndian.Uint16(h[2:]) != 1 {
+		c.t.Fatalf("got %v, want a FramebufferUpdate of one rectangle", h)
+	}
+	h := c.read(12)
+	x, y := int(binary.BigEndian.Uint16(h[0:])), int(binary.BigEndian.Uint16(h[2:]))
+	w, ht := int(binary.BigEndian.Uint16(h[4:])), int(binary.BigEndian.Uint16(h[6:]))
+	enc := int32(binary.BigEndian.Uint32(h[8:]))
+
+	pixels := make([]byte, 4*w*ht)
+	switch enc {
+	case encRaw:
+		copy(pixels, c.read(len(pixels)))
+	case encZlib:
+		c.zsrc.Write(c.read(int(binary.BigEndian.Uint32(c.read(4)))))
+		if c.zr == nil {
+			zr, err := zlib.NewReader(&c.zsrc)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+			c.zr = zr
+		}
+		if _, err := io.ReadFull(c.zr, pixels); err != nil {
+			c.t.Fatal(err)
+		}
+	default:
+		c.t.Fatalf("unexpected encoding %d", enc)
+	}
+	return image.Rect(x, y, x+w, y+ht), enc, pixels
+}
+
+func (c *rfbClient) pointer(mask byte, x, y uint16) {
+	c.t.Helper()
+	c.write(msgPointerEvent, mask, byte(x>>8), byte(x), byte(y>>8), byte(y))
+}
+
+func (c *rfbClient) key(down bool, sym uint32) {
+	c.t.Helper()
+	b := []byte{msgKeyEvent, 0, 0, 0, 0, 0, 0, 0}
+	if down {
+		b[1] = 1
+	}
+	binary.BigEndian.PutUint32(b[4:], sym)
+	c.write(b...)
+}
+
+// expect receives the events from the Env and compares them with want, "closed" standing for the
+// Events() channel getting closed.
+func expect(t *testing.T, env gui.Env, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		got := "closed"
+		select {
+		case e, ok := <-env.Events():
+			if ok {
+				got = e.String()
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no event within 3s, want %s", w)
+		}
+		if got != w {
+			t.Fatalf("got %s, want %s", got, w)
+		}
+	}
+}
+
+// fill fills the rectangle and waits until the change gets handed over to the clients.
+func fill(t *testing.T, s *Server, r image.Rectangle, c color.Color) {
+	t.Helper()
+	s.Draw() <- func(drw draw.Image) image.Rectangle {
+		draw.Draw(drw, r, &image.Uniform{c}, image.ZP, draw.Src)
+		return r
+	}
+	select {
+	case <-s.Frames():
+	case <-time.After(3 * time.Second):
+		t.Fatal("no frame within 3s")
+	}
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestRaw(t *testing.T) {
+	l := listen(t)
+	s := New(l, Size(64, 32), Name("test"))
+	expect(t, s, "resize/0/0/64/32")
+
+	c, refused := dial(t, s, "")
+	if refused != "" {
+		t.Fatalf("refused: %s", refused)
+	}
+	if c.size != image.Pt(64, 32) || c.name != "test" {
+		t.Errorf("got size %v and name %q, want (64,32) and \"test\"", c.size, c.name)
+	}
+
+	fill(t, s, s.img.Bounds(), color.RGBA{1, 2, 3, 255})
+	c.request(false, image.Rect(0, 0, 64, 32))
+	r, enc, pixels := c.update()
+	if r != image.Rect(0, 0, 64, 32) || enc != encRaw {
+		t.Errorf("got %v in encoding %d, want the whole screen in Raw", r, enc)
+	}
+	if !bytes.Equal(pixels, bytes.Repeat([]byte{3, 2, 1, 0}, 64*32)) {
+		t.Errorf("got pixels starting with %v, want blue, green, red and 0", pixels[:8])
+	}
+
+	// an incremental update waits for a change
+	c.request(true, image.Rect(0, 0, 64, 32))
+	fill(t, s, image.Rect(10, 10, 12, 11), color.RGBA{255, 0, 0, 255})
+	r, _, pixels = c.update()
+	if r != image.Rect(10, 10, 12, 11) || !bytes.Equal(pixels, []byte{0, 0, 255, 0, 0, 0, 255, 0}) {
+		t.Errorf("got %v with pixels %v, want two red pixels at (10,10)-(12,11)", r, pixels)
+	}
+
+	close(s.Draw())
+	expect(t, s, "closed")
+	if conn, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("the Server still listens after closing")
+	}
+}
+
+func TestZlibAndPassword(t *testing.T) {
+	s := New(listen(t), Size(100, 100), Password("secret"))
+	expect(t, s, "resize/0/0/100/100")
+
+	if _, refused := dial(t, s, "wrong"); refused != "authentication failed" {
+		t.Errorf("got %q with a wrong password, want a refusal", refused)
+	}
+
+	c, refused := dial(t, s, "secret")
+	if refused != "" {
+		t.Fatalf("refused: %s", refused)
+	}
+	c.write(msgSetEncodings, 0, 0, 2, 0, 0, 0, encZlib, 0, 0, 0, encRaw)
+	for i := 0; i < 3; i++ {
+		// the updates continue the same zlib stream
+		c.request(true, image.Rect(0, 0, 100, 100))
+		fill(t, s, image.Rect(i, i, 100, 100), color.RGBA{uint8(i), 100, 200, 255})
+		r, enc, pixels := c.update()
+		if r != image.Rect(i, i, 100, 100) || enc != encZlib {
+			t.Fatalf("got %v in encoding %d, want %v in Zlib", r, enc, image.Rect(i, i, 100, 100))
+		}
+		if want := bytes.Repeat([]byte{200, 100, uint8(i), 0}, r.Dx()*r.Dy()); !bytes.Equal(pixels, want) {
+			t.Fatalf("got pixels starting with %v, want %v", pixels[:4], want[:4])
+		}
+	}
+
+	close(s.Draw())
+	expect(t, s, "closed")
+}
+
+func TestInput(t *testing.T) {
+	s := New(listen(t), Size(64, 32))
+	expect(t, s, "resize/0/0/64/32")
+	c, refused := dial(t, s, "")
+	if refused != "" {
+		t.Fatalf("refused: %s", refused)
+	}
+
+	c.pointer(0, 5, 6)
+	c.pointer(1, 5, 6)    // left
+	c.pointer(0, 7, 6)    // move and release
+	c.pointer(1<<3, 7, 6) // wheel up
+	c.pointer(1<<2, 7, 6) // right
+	c.write(msgClientCutText, 0, 0, 0, 0, 0, 0, 3, 'a', 'b', 'c')
+	c.key(true, 'A')
+	c.key(false, 'A')
+	c.key(true, 0xff0d) // Enter
+	c.key(true, 0xff0d)
+	c.key(false, 0xff0d)
+	c.key(true, 0xffe3) // Ctrl
+	c.key(true, 'c')
+	c.key(false, 'c')
+	c.key(false, 0xffe3)
+	c.key(true, 0x010003b1) // α
+	expect(t, s,
+		"mo/move/5/6", "mo/down/5/6/left", "mo/move/7/6", "mo/up/7/6/left", "mo/scroll/0/1", "mo/down/7/6/right",
+		"kb/type/65", "kb/down/enter", "kb/repeat/enter", "kb/up/enter", "kb/down/ctrl", "kb/up/ctrl", "kb/type/945")
+
+	// disconnecting releases the buttons held down
+	c.conn.Close()
+	expect(t, s, "mo/up/7/6/right")
+
+	close(s.Draw())
+	expect(t, s, "closed")
+}
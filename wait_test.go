@@ -0,0 +1,120 @@
+package gui_test
+
+import (
+	"image"
+	"image/draw"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/headless"
+)
+
+// drawing is a component which keeps drawing until its Events() channel gets closed.
+func drawing(env gui.Env) {
+	for {
+		select {
+		case _, ok := <-env.Events():
+			if !ok {
+				close(env.Draw())
+				return
+			}
+		case env.Draw() <- func(drw draw.Image) image.Rectangle { return drw.Bounds() }:
+		}
+	}
+}
+
+// wait fails the test unless the function returns within a second.
+func wait(t *testing.T, what string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s did not return within 1s", what)
+	}
+}
+
+// checkNoLeak fails the test if f leaves more goroutines running than there were before.
+func checkNoLeak(t *testing.T, f func()) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	f()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		buf := make([]byte, 1<<16)
+		t.Errorf("%d goroutines before, %d after\n%s", before, n, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestWaitMasterClosedWhileDrawing(t *testing.T) {
+	checkNoLeak(t, func() {
+		root := headless.New(image.Rect(0, 0, 100, 100))
+		mux, master := gui.NewMux(root)
+		for i := 0; i < 5; i++ {
+			go drawing(mux.MakeEnv())
+			go drawing(mux.MakeChild(gui.Bounds(image.Rect(10*i, 10*i, 10*i+20, 10*i+20))))
+		}
+		go func() {
+			for range master.Events() {
+			}
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		close(master.Draw())
+		wait(t, "Wait", mux.Wait)
+		root.Close()
+	})
+}
+
+func TestWaitRootEventsClosed(t *testing.T) {
+	checkNoLeak(t, func() {
+		root := headless.New(image.Rect(0, 0, 100, 100))
+		mux, master := gui.NewMux(root)
+		for i := 0; i < 5; i++ {
+			go drawing(mux.MakeEnv())
+		}
+		go drawing(master)
+		time.Sleep(10 * time.Millisecond)
+
+		root.Close()
+		wait(t, "Wait", mux.Wait)
+		select {
+		case <-mux.Done():
+		default:
+			t.Error("Done() is open after Wait returned")
+		}
+	})
+}
+
+func TestWaitNestedChildClose(t *testing.T) {
+	checkNoLeak(t, func() {
+		root := headless.New(image.Rect(0, 0, 100, 100))
+		mux, master := gui.NewMux(root)
+		go drawing(master)
+
+		panel := mux.MakeChild(gui.Bounds(image.Rect(10, 10, 60, 60)))
+		nested, nestedMaster := gui.NewMux(panel)
+		go drawing(nestedMaster)
+		for i := 0; i < 5; i++ {
+			go drawing(nested.MakeEnv())
+			go drawing(nested.MakeChild(gui.Bounds(image.Rect(10+i, 10+i, 30+i, 30+i))))
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		panel.Close()
+		wait(t, "Wait of the nested Mux", nested.Wait)
+		wait(t, "Done of the Child", func() { <-panel.Done() })
+
+		root.Close()
+		wait(t, "Wait", mux.Wait)
+	})
+}
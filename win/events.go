@@ -1,8 +1,16 @@
+// Package win implements a window, a gui.Env, using GLFW and OpenGL, along with the events
+// produced by the windows and by the other backends, such as x11, vnc or term.
+//
+// The window uses cgo, so its source file has the cgo build constraint. With cgo disabled,
+// New and its options are missing, while the events, keys and buttons stay available, so the
+// pure Go backends can be built without a C compiler.
 package win
 
 import (
 	"fmt"
 	"image"
+
+	"github.com/faiface/gui"
 )
 
 // Button indicates a mouse button in an event.
@@ -87,3 +95,45 @@ func (kt KbType) String() string   { return fmt.Sprintf("kb/type/%d", kt.Rune) }
 func (kd KbDown) String() string   { return fmt.Sprintf("kb/down/%s", kd.Key) }
 func (ku KbUp) String() string     { return fmt.Sprintf("kb/up/%s", ku.Key) }
 func (kr KbRepeat) String() string { return fmt.Sprintf("kb/repeat/%s", kr.Key) }
+
+func (mm MoMove) ButtonState() (string, bool)   { return "", false }
+func (md MoDown) ButtonState() (string, bool)   { return string(md.Button), true }
+func (mu MoUp) ButtonState() (string, bool)     { return string(mu.Button), false }
+func (ms MoScroll) ButtonState() (string, bool) { return "", false }
+
+func (kt KbType) KeyState() (string, bool)   { return "", false }
+func (kd KbDown) KeyState() (string, bool)   { return string(kd.Key), true }
+func (ku KbUp) KeyState() (string, bool)     { return string(ku.Key), false }
+func (kr KbRepeat) KeyState() (string, bool) { return string(kr.Key), true }
+
+func (mm MoMove) At() image.Point { return mm.Point }
+func (md MoDown) At() image.Point { return md.Point }
+func (mu MoUp) At() image.Point   { return mu.Point }
+
+func (mm MoMove) Translate(delta image.Point) gui.PointEvent {
+	return MoMove{mm.Point.Add(delta)}
+}
+
+func (md MoDown) Translate(delta image.Point) gui.PointEvent {
+	return MoDown{md.Point.Add(delta), md.Button}
+}
+
+func (mu MoUp) Translate(delta image.Point) gui.PointEvent {
+	return MoUp{mu.Point.Add(delta), mu.Button}
+}
+
+// Coalesce replaces a previous MoMove with the new one.
+func (mm MoMove) Coalesce(prev gui.Event) (gui.Event, bool) {
+	if _, ok := prev.(MoMove); ok {
+		return mm, true
+	}
+	return nil, false
+}
+
+// Coalesce sums the amount scrolled with a previous MoScroll.
+func (ms MoScroll) Coalesce(prev gui.Event) (gui.Event, bool) {
+	if prev, ok := prev.(MoScroll); ok {
+		return MoScroll{prev.Point.Add(ms.Point)}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,159 @@
+package win
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/faiface/gui"
+)
+
+func init() {
+	gui.RegisterEvent("wi/close", parseWiClose)
+	gui.RegisterEvent("mo/move/", parseMoMove)
+	gui.RegisterEvent("mo/down/", parseMoDown)
+	gui.RegisterEvent("mo/up/", parseMoUp)
+	gui.RegisterEvent("mo/scroll/", parseMoScroll)
+	gui.RegisterEvent("kb/type/", parseKbType)
+	gui.RegisterEvent("kb/down/", parseKbDown)
+	gui.RegisterEvent("kb/up/", parseKbUp)
+	gui.RegisterEvent("kb/repeat/", parseKbRepeat)
+}
+
+var allButtons = map[Button]bool{
+	ButtonLeft:   true,
+	ButtonRight:  true,
+	ButtonMiddle: true,
+}
+
+var allKeys = map[Key]bool{
+	KeyLeft:      true,
+	KeyRight:     true,
+	KeyUp:        true,
+	KeyDown:      true,
+	KeyEscape:    true,
+	KeySpace:     true,
+	KeyBackspace: true,
+	KeyDelete:    true,
+	KeyEnter:     true,
+	KeyTab:       true,
+	KeyHome:      true,
+	KeyEnd:       true,
+	KeyPageUp:    true,
+	KeyPageDown:  true,
+	KeyShift:     true,
+	KeyCtrl:      true,
+	KeyAlt:       true,
+}
+
+func parseWiClose(s string) (gui.Event, error) {
+	if s != "wi/close" {
+		return nil, fmt.Errorf("win: invalid event %q", s)
+	}
+	return WiClose{}, nil
+}
+
+func parsePoint(s string, n int) (image.Point, []string, error) {
+	fields, err := gui.SplitEvent(s, n)
+	if err != nil {
+		return image.ZP, nil, fmt.Errorf("win: %v", err)
+	}
+	xy, err := gui.ParseEventInts(fields[2:4]...)
+	if err != nil {
+		return image.ZP, nil, fmt.Errorf("win: event %q: %w", s, err)
+	}
+	return image.Pt(xy[0], xy[1]), fields, nil
+}
+
+func parseButton(s, b string) (Button, error) {
+	if !allButtons[Button(b)] {
+		return "", fmt.Errorf("win: event %q: unknown button %q", s, b)
+	}
+	return Button(b), nil
+}
+
+func parseKey(s string) (Key, error) {
+	fields, err := gui.SplitEvent(s, 3)
+	if err != nil {
+		return "", fmt.Errorf("win: %v", err)
+	}
+	if !allKeys[Key(fields[2])] {
+		return "", fmt.Errorf("win: event %q: unknown key %q", s, fields[2])
+	}
+	return Key(fields[2]), nil
+}
+
+func parseMoMove(s string) (gui.Event, error) {
+	p, _, err := parsePoint(s, 4)
+	if err != nil {
+		return nil, err
+	}
+	return MoMove{p}, nil
+}
+
+func parseMoDown(s string) (gui.Event, error) {
+	p, fields, err := parsePoint(s, 5)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseButton(s, fields[4])
+	if err != nil {
+		return nil, err
+	}
+	return MoDown{p, b}, nil
+}
+
+func parseMoUp(s string) (gui.Event, error) {
+	p, fields, err := parsePoint(s, 5)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseButton(s, fields[4])
+	if err != nil {
+		return nil, err
+	}
+	return MoUp{p, b}, nil
+}
+
+func parseMoScroll(s string) (gui.Event, error) {
+	p, _, err := parsePoint(s, 4)
+	if err != nil {
+		return nil, err
+	}
+	return MoScroll{p}, nil
+}
+
+func parseKbType(s string) (gui.Event, error) {
+	fields, err := gui.SplitEvent(s, 3)
+	if err != nil {
+		return nil, fmt.Errorf("win: %v", err)
+	}
+	r, err := gui.ParseEventInts(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("win: event %q: %w", s, err)
+	}
+	return KbType{rune(r[0])}, nil
+}
+
+func parseKbDown(s string) (gui.Event, error) {
+	k, err := parseKey(s)
+	if err != nil {
+		return nil, err
+	}
+	return KbDown{k}, nil
+}
+
+func parseKbUp(s string) (gui.Event, error) {
+	k, err := parseKey(s)
+	if err != nil {
+		return nil, err
+	}
+	return KbUp{k}, nil
+}
+
+func parseKbRepeat(s string) (gui.Event, error) {
+	k, err := parseKey(s)
+	if err != nil {
+		return nil, err
+	}
+	return KbRepeat{k}, nil
+}
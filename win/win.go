@@ -1,3 +1,6 @@
+//go:build cgo
+// +build cgo
+
 package win
 
 import (
@@ -22,6 +25,8 @@ type options struct {
 	resizable     bool
 	borderless    bool
 	maximized     bool
+	coalesce      bool
+	recovery      gui.Recovery
 }
 
 // Title option sets the title (caption) of the window.
@@ -60,6 +65,24 @@ func Maximized() Option {
 	}
 }
 
+// CoalesceEvents option makes the window merge consecutive mouse moves, scrolls and resizes
+// which haven't been received yet. See gui.MakeCoalescingEventsChan.
+func CoalesceEvents() Option {
+	return func(o *options) {
+		o.coalesce = true
+	}
+}
+
+// RecoverPanics option makes the window recover the panics in the draw functions sent to it,
+// instead of crashing the whole program. Each panic gets sent to errs as a *gui.PanicError,
+// unless errs is nil. The window does not block sending to errs: if errs is not ready, the
+// error gets dropped.
+func RecoverPanics(errs chan<- error) Option {
+	return func(o *options) {
+		o.recovery = gui.Recovery{Recover: true, Errs: errs}
+	}
+}
+
 // New creates a new window with all the supplied options.
 //
 // The default title is empty and the default size is 640x480.
@@ -71,19 +94,25 @@ func New(opts ...Option) (*Win, error) {
 		resizable:  false,
 		borderless: false,
 		maximized:  false,
+		coalesce:   false,
 	}
 	for _, opt := range opts {
 		opt(&o)
 	}
 
 	eventsOut, eventsIn := gui.MakeEventsChan()
+	if o.coalesce {
+		eventsOut, eventsIn = gui.MakeCoalescingEventsChan()
+	}
 
 	w := &Win{
 		eventsOut: eventsOut,
 		eventsIn:  eventsIn,
 		draw:      make(chan func(draw.Image) image.Rectangle),
 		newSize:   make(chan image.Rectangle),
+		frames:    make(chan time.Time, 1),
 		finish:    make(chan struct{}),
+		recovery:  o.recovery,
 	}
 
 	var err error
@@ -163,8 +192,11 @@ type Win struct {
 	draw      chan func(draw.Image) image.Rectangle
 
 	newSize chan image.Rectangle
+	frames  chan time.Time
 	finish  chan struct{}
 
+	recovery gui.Recovery
+
 	w     *glfw.Window
 	img   *image.RGBA
 	ratio int
@@ -176,6 +208,11 @@ func (w *Win) Events() <-chan gui.Event { return w.eventsOut }
 // Draw returns the draw channel of the window.
 func (w *Win) Draw() chan<- func(draw.Image) image.Rectangle { return w.draw }
 
+// Frames returns a channel that receives the time of each flush of the window to the screen.
+// The window does not block sending to it, so the frames nobody receives get dropped. See
+// anim.Framer.
+func (w *Win) Frames() <-chan time.Time { return w.frames }
+
 var buttons = map[glfw.MouseButton]Button{
 	glfw.MouseButtonLeft:   ButtonLeft,
 	glfw.MouseButtonRight:  ButtonRight,
@@ -296,7 +333,7 @@ loop:
 				close(w.finish)
 				return
 			}
-			r := d(w.img)
+			r := w.recovery.Draw(w, d, w.img)
 			totalR = totalR.Union(r)
 		}
 
@@ -304,6 +341,12 @@ loop:
 			select {
 			case <-time.After(time.Second / 960):
 				w.openGLFlush(totalR)
+				if !totalR.Empty() {
+					select {
+					case w.frames <- time.Now():
+					default:
+					}
+				}
 				totalR = image.ZR
 				continue loop
 
@@ -318,7 +361,7 @@ loop:
 					close(w.finish)
 					return
 				}
-				r := d(w.img)
+				r := w.recovery.Draw(w, d, w.img)
 				totalR = totalR.Union(r)
 			}
 		}
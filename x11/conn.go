@@ -0,0 +1,383 @@
+package x11
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// conn is a connection to an X server speaking the X11 wire protocol. All the requests are
+// encoded in the little-endian byte order.
+type conn struct {
+	c net.Conn
+	r *bufio.Reader
+
+	mu sync.Mutex // protects writing requests
+
+	setup  setup
+	nextID uint32
+
+	// events received while waiting for a reply
+	queued [][]byte
+}
+
+type setup struct {
+	resourceBase, resourceMask uint32
+	maxRequestLen              int // in bytes
+	imageMSBFirst              bool
+	minKeycode, maxKeycode     byte
+
+	root       uint32
+	rootVisual uint32
+	rootDepth  byte
+	bpp        byte // bits per pixel of the root depth
+	visualMask [3]uint32
+}
+
+// display describes the value of the DISPLAY environment variable.
+type display struct {
+	network, address string
+	number           string
+}
+
+// parseDisplay parses a display name of the form [host]:number[.screen].
+func parseDisplay(name string) (display, error) {
+	i := strings.LastIndex(name, ":")
+	if i == -1 {
+		return display{}, fmt.Errorf("x11: invalid display %q", name)
+	}
+	host, number := name[:i], name[i+1:]
+	if j := strings.Index(number, "."); j != -1 {
+		number = number[:j]
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return display{}, fmt.Errorf("x11: invalid display %q", name)
+	}
+	switch {
+	case host == "" || host == "unix":
+		return display{"unix", "/tmp/.X11-unix/X" + number, number}, nil
+	case strings.HasPrefix(host, "/"):
+		return display{"unix", name, number}, nil
+	default:
+		return display{"tcp", net.JoinHostPort(host, strconv.Itoa(6000+n)), number}, nil
+	}
+}
+
+const (
+	familyInternet  = 0
+	familyInternet6 = 6
+	familyLocal     = 256
+	familyWild      = 65535
+)
+
+// readAuth finds the MIT-MAGIC-COOKIE-1 for the display, connected to at the remote address, in
+// the Xauthority file. It returns no cookie if there is none, in which case the server may still
+// accept the connection.
+func readAuth(d display, remote net.Addr) (name string, data []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		path = filepath.Join(home, ".Xauthority")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil
+	}
+	defer f.Close()
+
+	var ip net.IP
+	if addr, ok := remote.(*net.TCPAddr); ok {
+		ip = addr.IP
+	}
+	hostname, _ := os.Hostname()
+	return findAuth(bufio.NewReader(f), d, ip, hostname)
+}
+
+// findAuth reads the entries of an Xauthority file and returns the first cookie for the display.
+// A cookie of the local host, stored under its hostname, is used for the Unix sockets and for the
+// TCP connections to a loopback address, a cookie of an Internet address only for the
+// connections to that address.
+func findAuth(r io.Reader, d display, ip net.IP, hostname string) (name string, data []byte) {
+	readField := func() ([]byte, error) {
+		var n uint16
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	local := d.network == "unix" || ip.IsLoopback()
+	for {
+		var family uint16
+		if err := binary.Read(r, binary.BigEndian, &family); err != nil {
+			return "", nil
+		}
+		var fields [4][]byte // address, number, name, data
+		for i := range fields {
+			var err error
+			if fields[i], err = readField(); err != nil {
+				return "", nil
+			}
+		}
+		address, number, authName := fields[0], string(fields[1]), string(fields[2])
+		if authName != "MIT-MAGIC-COOKIE-1" || (number != "" && number != d.number) {
+			continue
+		}
+		switch {
+		case family == familyWild,
+			family == familyLocal && local && string(address) == hostname,
+			family == familyInternet && ip.To4() != nil && ip.To4().Equal(address),
+			family == familyInternet6 && ip.To4() == nil && ip.To16() != nil && ip.Equal(address):
+			return authName, fields[3]
+		}
+	}
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+// dial connects to the X server and performs the connection setup.
+func dial(name string) (*conn, error) {
+	if name == "" {
+		name = os.Getenv("DISPLAY")
+	}
+	if name == "" {
+		return nil, errors.New("x11: no display, DISPLAY is not set")
+	}
+	d, err := parseDisplay(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.Dial(d.network, d.address)
+	if err != nil {
+		return nil, fmt.Errorf("x11: %v", err)
+	}
+	authName, authData := readAuth(d, c.RemoteAddr())
+	cn, err := handshake(c, authName, authData)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// handshake performs the connection setup over c.
+func handshake(c net.Conn, authName string, authData []byte) (*conn, error) {
+	req := []byte{'l', 0, 11, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(req[6:], uint16(len(authName)))
+	binary.LittleEndian.PutUint16(req[8:], uint16(len(authData)))
+	req = append(req, authName...)
+	req = append(req, make([]byte, pad4(len(authName)))...)
+	req = append(req, authData...)
+	req = append(req, make([]byte, pad4(len(authData)))...)
+	if _, err := c.Write(req); err != nil {
+		return nil, fmt.Errorf("x11: %v", err)
+	}
+
+	r := bufio.NewReaderSize(c, 64*1024)
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, fmt.Errorf("x11: connection setup: %v", err)
+	}
+	body := make([]byte, 4*int(binary.LittleEndian.Uint16(head[6:])))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("x11: connection setup: %v", err)
+	}
+	switch head[0] {
+	case 0:
+		reason := body
+		if int(head[1]) <= len(reason) {
+			reason = reason[:head[1]]
+		}
+		return nil, fmt.Errorf("x11: connection refused: %s", strings.TrimSpace(string(reason)))
+	case 2:
+		return nil, fmt.Errorf("x11: connection refused: %s", strings.TrimRight(string(body), "\x00"))
+	}
+
+	s, err := parseSetup(body)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{c: c, r: r, setup: s}, nil
+}
+
+func parseSetup(b []byte) (s setup, err error) {
+	le := binary.LittleEndian
+	if len(b) < 32 {
+		return s, errors.New("x11: connection setup: reply too short")
+	}
+	s.resourceBase = le.Uint32(b[4:])
+	s.resourceMask = le.Uint32(b[8:])
+	vendorLen := int(le.Uint16(b[16:]))
+	s.maxRequestLen = 4 * int(le.Uint16(b[18:]))
+	numScreens, numFormats := int(b[20]), int(b[21])
+	s.imageMSBFirst = b[22] == 1
+	s.minKeycode, s.maxKeycode = b[26], b[27]
+
+	off := 32 + vendorLen + pad4(vendorLen)
+	formats := make(map[byte]byte) // depth -> bits per pixel
+	for i := 0; i < numFormats; i++ {
+		if off+8 > len(b) {
+			return s, errors.New("x11: connection setup: reply too short")
+		}
+		formats[b[off]] = b[off+1]
+		off += 8
+	}
+	if numScreens == 0 || off+40 > len(b) {
+		return s, errors.New("x11: connection setup: no screens")
+	}
+
+	// only the first screen is used
+	s.root = le.Uint32(b[off:])
+	s.rootVisual = le.Uint32(b[off+32:])
+	s.rootDepth = b[off+38]
+	numDepths := int(b[off+39])
+	off += 40
+	found := false
+	for i := 0; i < numDepths; i++ {
+		if off+8 > len(b) {
+			return s, errors.New("x11: connection setup: reply too short")
+		}
+		numVisuals := int(le.Uint16(b[off+2:]))
+		off += 8
+		for j := 0; j < numVisuals; j++ {
+			if off+24 > len(b) {
+				return s, errors.New("x11: connection setup: reply too short")
+			}
+			if le.Uint32(b[off:]) == s.rootVisual {
+				found = true
+				s.visualMask = [3]uint32{le.Uint32(b[off+8:]), le.Uint32(b[off+12:]), le.Uint32(b[off+16:])}
+			}
+			off += 24
+		}
+	}
+	s.bpp = formats[s.rootDepth]
+	if !found || s.bpp != 32 || s.visualMask != [3]uint32{0xff0000, 0xff00, 0xff} {
+		return s, fmt.Errorf("x11: unsupported visual: depth %d, %d bits per pixel", s.rootDepth, s.bpp)
+	}
+	return s, nil
+}
+
+// newID allocates a new resource ID.
+func (c *conn) newID() uint32 {
+	c.nextID++
+	return c.setup.resourceBase | (c.nextID & c.setup.resourceMask)
+}
+
+// request encodes a request. The length field gets filled in by send.
+type request []byte
+
+func newRequest(opcode, data byte) request {
+	return request{opcode, data, 0, 0}
+}
+
+func (r request) u8(v byte) request { return append(r, v) }
+
+func (r request) u16(v uint16) request {
+	return append(r, byte(v), byte(v>>8))
+}
+
+func (r request) u32(v uint32) request {
+	return append(r, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (r request) bytes(b []byte) request {
+	r = append(r, b...)
+	return append(r, make([]byte, pad4(len(b)))...)
+}
+
+// send writes the requests to the server.
+func (c *conn) send(reqs ...request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range reqs {
+		binary.LittleEndian.PutUint16(r[2:], uint16(len(r)/4))
+		if _, err := c.c.Write(r); err != nil {
+			return fmt.Errorf("x11: %v", err)
+		}
+	}
+	return nil
+}
+
+// readPacket reads an event, an error or a reply from the server.
+func (c *conn) readPacket() ([]byte, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return nil, err
+	}
+	if b[0] == 1 { // reply
+		extra := make([]byte, 4*int(binary.LittleEndian.Uint32(b[4:])))
+		if _, err := io.ReadFull(c.r, extra); err != nil {
+			return nil, err
+		}
+		b = append(b, extra...)
+	}
+	return b, nil
+}
+
+// roundTrip sends a request expecting a reply and waits for the reply. The events received in
+// the meantime are kept for nextEvent. It must not be used once nextEvent is being called from
+// another goroutine.
+func (c *conn) roundTrip(r request) ([]byte, error) {
+	if err := c.send(r); err != nil {
+		return nil, err
+	}
+	for {
+		b, err := c.readPacket()
+		if err != nil {
+			return nil, fmt.Errorf("x11: %v", err)
+		}
+		switch b[0] {
+		case 0:
+			return nil, protocolError(b)
+		case 1:
+			return b, nil
+		default:
+			c.queued = append(c.queued, b)
+		}
+	}
+}
+
+// nextEvent returns the next event or error from the server.
+func (c *conn) nextEvent() ([]byte, error) {
+	if len(c.queued) > 0 {
+		b := c.queued[0]
+		c.queued = c.queued[1:]
+		return b, nil
+	}
+	return c.readPacket()
+}
+
+// peekEvent returns the next event if it was received already, without blocking.
+func (c *conn) peekEvent() []byte {
+	if len(c.queued) > 0 {
+		return c.queued[0]
+	}
+	if c.r.Buffered() < 32 {
+		return nil
+	}
+	b, _ := c.r.Peek(32)
+	return b
+}
+
+func protocolError(b []byte) error {
+	return fmt.Errorf("x11: protocol error %d in request %d", b[1], b[10])
+}
+
+func (c *conn) close() error {
+	return c.c.Close()
+}
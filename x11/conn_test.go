@@ -0,0 +1,60 @@
+package x11
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// authEntry encodes an entry of an Xauthority file.
+func authEntry(family uint16, address, number, data string) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, family)
+	for _, field := range []string{address, number, "MIT-MAGIC-COOKIE-1", data} {
+		binary.Write(&b, binary.BigEndian, uint16(len(field)))
+		b.WriteString(field)
+	}
+	return b.Bytes()
+}
+
+func TestFindAuth(t *testing.T) {
+	var file []byte
+	for _, entry := range [][]byte{
+		authEntry(familyLocal, "myhost", "0", "local"),
+		authEntry(familyInternet, string(net.IPv4(10, 0, 0, 1).To4()), "0", "inet-10.0.0.1"),
+		authEntry(familyInternet, string(net.IPv4(10, 0, 0, 2).To4()), "0", "inet-10.0.0.2"),
+		authEntry(familyInternet6, string(net.ParseIP("fd00::1")), "0", "inet6-fd00::1"),
+		authEntry(familyInternet, string(net.IPv4(10, 0, 0, 3).To4()), "1", "inet-10.0.0.3:1"),
+	} {
+		file = append(file, entry...)
+	}
+
+	unix := display{"unix", "/tmp/.X11-unix/X0", "0"}
+	tcp := display{"tcp", "", "0"}
+	tests := []struct {
+		name string
+		d    display
+		ip   net.IP
+		want string
+	}{
+		{"unix socket", unix, nil, "local"},
+		{"loopback", tcp, net.IPv4(127, 0, 0, 1), "local"},
+		{"first address", tcp, net.IPv4(10, 0, 0, 1), "inet-10.0.0.1"},
+		{"second address", tcp, net.IPv4(10, 0, 0, 2), "inet-10.0.0.2"},
+		{"IPv6 address", tcp, net.ParseIP("fd00::1"), "inet6-fd00::1"},
+		{"unknown address", tcp, net.IPv4(10, 0, 0, 9), ""},
+		{"other display", tcp, net.IPv4(10, 0, 0, 3), ""},
+	}
+	for _, tt := range tests {
+		_, data := findAuth(bytes.NewReader(file), tt.d, tt.ip, "myhost")
+		if string(data) != tt.want {
+			t.Errorf("%s: got cookie %q, want %q", tt.name, data, tt.want)
+		}
+	}
+
+	wild := append(authEntry(familyWild, "", "", "wild"), file...)
+	if _, data := findAuth(bytes.NewReader(wild), tcp, net.IPv4(10, 0, 0, 9), "myhost"); string(data) != "wild" {
+		t.Errorf("got cookie %q, want the wildcard one", data)
+	}
+}
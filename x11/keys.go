@@ -0,0 +1,114 @@
+package x11
+
+import (
+	"encoding/binary"
+	"unicode"
+
+	"github.com/faiface/gui/win"
+)
+
+// keysyms of the keys reported in KbDown, KbUp and KbRepeat events
+var keys = map[uint32]win.Key{
+	0xff51: win.KeyLeft,
+	0xff53: win.KeyRight,
+	0xff52: win.KeyUp,
+	0xff54: win.KeyDown,
+	0xff1b: win.KeyEscape,
+	0x0020: win.KeySpace,
+	0xff08: win.KeyBackspace,
+	0xffff: win.KeyDelete,
+	0xff0d: win.KeyEnter,
+	0xff8d: win.KeyEnter, // KP_Enter
+	0xff09: win.KeyTab,
+	0xfe20: win.KeyTab, // ISO_Left_Tab
+	0xff50: win.KeyHome,
+	0xff57: win.KeyEnd,
+	0xff55: win.KeyPageUp,
+	0xff56: win.KeyPageDown,
+	0xffe1: win.KeyShift,
+	0xffe2: win.KeyShift,
+	0xffe3: win.KeyCtrl,
+	0xffe4: win.KeyCtrl,
+	0xffe9: win.KeyAlt,
+	0xffea: win.KeyAlt,
+}
+
+const (
+	shiftMask   = 1 << 0
+	lockMask    = 1 << 1
+	controlMask = 1 << 2
+)
+
+// keymap maps keycodes to keysyms.
+type keymap struct {
+	minKeycode byte
+	perKeycode int
+	keysyms    []uint32
+}
+
+func parseKeymap(minKeycode byte, reply []byte) keymap {
+	km := keymap{minKeycode: minKeycode, perKeycode: int(reply[1])}
+	for b := reply[32:]; len(b) >= 4; b = b[4:] {
+		km.keysyms = append(km.keysyms, binary.LittleEndian.Uint32(b))
+	}
+	return km
+}
+
+// keysym returns the keysym of the keycode in the column, or 0 (NoSymbol).
+func (km keymap) keysym(keycode byte, column int) uint32 {
+	if keycode < km.minKeycode || column >= km.perKeycode {
+		return 0
+	}
+	i := int(keycode-km.minKeycode)*km.perKeycode + column
+	if i >= len(km.keysyms) {
+		return 0
+	}
+	return km.keysyms[i]
+}
+
+// key returns the key of the keycode, judged by its unshifted keysym.
+func (km keymap) key(keycode byte) (win.Key, bool) {
+	k, ok := keys[km.keysym(keycode, 0)]
+	return k, ok
+}
+
+// rune returns the character typed by the keycode with the modifier state, if any.
+func (km keymap) rune(keycode byte, state uint16) (rune, bool) {
+	if state&controlMask != 0 {
+		return 0, false
+	}
+	lower, upper := km.keysym(keycode, 0), km.keysym(keycode, 1)
+	if upper == 0 {
+		upper = lower
+	}
+	sym := lower
+	if state&shiftMask != 0 {
+		sym = upper
+	}
+	r, ok := keysymRune(sym)
+	if !ok {
+		return 0, false
+	}
+	if state&lockMask != 0 && unicode.IsLetter(r) {
+		if state&shiftMask != 0 {
+			r = unicode.ToLower(r)
+		} else {
+			r = unicode.ToUpper(r)
+		}
+	}
+	return r, true
+}
+
+// keysymRune converts a keysym of a printable character to the character.
+func keysymRune(sym uint32) (rune, bool) {
+	var r rune
+	switch {
+	case sym >= 0x20 && sym <= 0x7e, sym >= 0xa0 && sym <= 0xff:
+		r = rune(sym) // Latin-1 keysyms match Unicode
+	case sym&0xff000000 == 0x01000000:
+		r = rune(sym & 0x00ffffff) // Unicode keysyms
+	default:
+		return 0, false
+	}
+	return r, unicode.IsPrint(r)
+}
@@ -0,0 +1,437 @@
+// Package x11 implements a window, a gui.Env, talking to an X server directly over the X11
+// wire protocol. It is written in pure Go, so it needs no C libraries, no OpenGL and no cgo.
+//
+// The window produces the same events as the windows of package win.
+package x11
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/faiface/gui"
+	"github.com/faiface/gui/internal/damage"
+	"github.com/faiface/gui/win"
+)
+
+// Option is a functional option to the window constructor New.
+type Option func(*options)
+
+type options struct {
+	display       string
+	title         string
+	width, height int
+	resizable     bool
+	coalesce      bool
+	recovery      gui.Recovery
+}
+
+// Display option sets the X display to connect to, such as ":0". By default, the DISPLAY
+// environment variable is used.
+func Display(name string) Option {
+	return func(o *options) {
+		o.display = name
+	}
+}
+
+// Title option sets the title (caption) of the window.
+func Title(title string) Option {
+	return func(o *options) {
+		o.title = title
+	}
+}
+
+// Size option sets the width and height of the window.
+func Size(width, height int) Option {
+	return func(o *options) {
+		o.width = width
+		o.height = height
+	}
+}
+
+// Resizable option makes the window resizable by the user.
+func Resizable() Option {
+	return func(o *options) {
+		o.resizable = true
+	}
+}
+
+// CoalesceEvents option makes the window merge the consecutive mouse moves, scrolls and resizes
+// which haven't been received yet, like win.CoalesceEvents. Over a remote X connection, the
+// events tend to arrive in bursts, which it evens out. See gui.MakeCoalescingEventsChan.
+func CoalesceEvents() Option {
+	return func(o *options) {
+		o.coalesce = true
+	}
+}
+
+// RecoverPanics option makes the window recover the panics in the draw functions sent to it and
+// report them to errs, like win.RecoverPanics. See gui.Recovery.
+func RecoverPanics(errs chan<- error) Option {
+	return func(o *options) {
+		o.recovery = gui.Recovery{Recover: true, Errs: errs}
+	}
+}
+
+// X11 opcodes, event codes and masks used by the window
+const (
+	opCreateWindow       = 1
+	opDestroyWindow      = 4
+	opMapWindow          = 8
+	opInternAtom         = 16
+	opChangeProperty     = 18
+	opCreateGC           = 55
+	opFreeGC             = 60
+	opPutImage           = 72
+	opGetKeyboardMapping = 101
+
+	evKeyPress        = 2
+	evKeyRelease      = 3
+	evButtonPress     = 4
+	evButtonRelease   = 5
+	evMotionNotify    = 6
+	evExpose          = 12
+	evConfigureNotify = 22
+	evClientMessage   = 33
+
+	eventMask = 1<<0 | 1<<1 | 1<<2 | 1<<3 | 1<<6 | 1<<15 | 1<<17 // keys, buttons, motion, exposure, structure
+
+	atomAtom         = 4
+	atomString       = 31
+	atomWMName       = 39
+	atomWMNormalHint = 40
+	atomWMSizeHints  = 41
+)
+
+// New creates a new window with all the supplied options.
+//
+// The default title is empty and the default size is 640x480.
+func New(opts ...Option) (*Win, error) {
+	o := options{
+		title:  "",
+		width:  640,
+		height: 480,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c, err := dial(o.display)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newWin(c, &o)
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func newWin(c *conn, o *options) (*Win, error) {
+	eventsOut, eventsIn := gui.MakeEventsChan()
+	if o.coalesce {
+		eventsOut, eventsIn = gui.MakeCoalescingEventsChan()
+	}
+
+	w := &Win{
+		eventsOut: eventsOut,
+		eventsIn:  eventsIn,
+		draw:      make(chan func(draw.Image) image.Rectangle),
+		newSize:   make(chan image.Rectangle),
+		expose:    make(chan image.Rectangle),
+		damage:    damage.New(),
+		finish:    make(chan struct{}),
+		recovery:  o.recovery,
+		c:         c,
+		img:       image.NewRGBA(image.Rect(0, 0, o.width, o.height)),
+	}
+
+	intern := func(name string) (uint32, error) {
+		reply, err := c.roundTrip(newRequest(opInternAtom, 0).
+			u16(uint16(len(name))).u16(0).bytes([]byte(name)))
+		if err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint32(reply[8:]), nil
+	}
+	var (
+		atoms [4]uint32
+		err   error
+	)
+	for i, name := range []string{"WM_PROTOCOLS", "WM_DELETE_WINDOW", "_NET_WM_NAME", "UTF8_STRING"} {
+		if atoms[i], err = intern(name); err != nil {
+			return nil, err
+		}
+	}
+	w.wmProtocols, w.wmDeleteWindow = atoms[0], atoms[1]
+
+	s := c.setup
+	reply, err := c.roundTrip(newRequest(opGetKeyboardMapping, 0).
+		u8(s.minKeycode).u8(s.maxKeycode - s.minKeycode + 1).u16(0))
+	if err != nil {
+		return nil, err
+	}
+	w.keymap = parseKeymap(s.minKeycode, reply)
+
+	w.window, w.gc = c.newID(), c.newID()
+	reqs := []request{
+		newRequest(opCreateWindow, s.rootDepth).
+			u32(w.window).u32(s.root).
+			u16(0).u16(0).u16(uint16(o.width)).u16(uint16(o.height)).
+			u16(0).u16(1). // border width, InputOutput
+			u32(s.rootVisual).
+			u32(1 << 11).u32(eventMask), // event-mask
+		newRequest(opChangeProperty, 0).
+			u32(w.window).u32(atomWMName).u32(atomString).
+			u8(8).u8(0).u16(0).u32(uint32(len(o.title))).bytes([]byte(o.title)),
+		newRequest(opChangeProperty, 0).
+			u32(w.window).u32(atoms[2]).u32(atoms[3]).
+			u8(8).u8(0).u16(0).u32(uint32(len(o.title))).bytes([]byte(o.title)),
+		newRequest(opChangeProperty, 0).
+			u32(w.window).u32(w.wmProtocols).u32(atomAtom).
+			u8(32).u8(0).u16(0).u32(1).u32(w.wmDeleteWindow),
+	}
+	if !o.resizable {
+		// WM_SIZE_HINTS with the minimum and the maximum size set to the size
+		hints := newRequest(opChangeProperty, 0).
+			u32(w.window).u32(atomWMNormalHint).u32(atomWMSizeHints).
+			u8(32).u8(0).u16(0).u32(18).
+			u32(1<<4 | 1<<5).u32(0).u32(0).u32(0).u32(0).
+			u32(uint32(o.width)).u32(uint32(o.height)).u32(uint32(o.width)).u32(uint32(o.height))
+		for i := 0; i < 9; i++ {
+			hints = hints.u32(0)
+		}
+		reqs = append(reqs, hints)
+	}
+	reqs = append(reqs,
+		newRequest(opCreateGC, 0).u32(w.gc).u32(w.window).u32(0),
+		newRequest(opMapWindow, 0).u32(w.window),
+	)
+	if err := c.send(reqs...); err != nil {
+		return nil, err
+	}
+
+	w.eventsIn <- gui.Resize{Rectangle: w.img.Bounds()}
+
+	go w.drawLoop()
+	go w.eventLoop()
+
+	return w, nil
+}
+
+// Win is an Env that handles a window of an X server.
+//
+// It receives its events from the X server and it draws to the window by uploading the changed
+// parts of its image.
+type Win struct {
+	eventsOut <-chan gui.Event
+	eventsIn  chan<- gui.Event
+	draw      chan func(draw.Image) image.Rectangle
+
+	newSize chan image.Rectangle
+	expose  chan image.Rectangle
+	damage  *damage.Damage
+	finish  chan struct{}
+
+	recovery gui.Recovery
+
+	c              *conn
+	window, gc     uint32
+	wmProtocols    uint32
+	wmDeleteWindow uint32
+	keymap         keymap
+	img            *image.RGBA
+}
+
+// Events returns the events channel of the window.
+func (w *Win) Events() <-chan gui.Event { return w.eventsOut }
+
+// Draw returns the draw channel of the window.
+func (w *Win) Draw() chan<- func(draw.Image) image.Rectangle { return w.draw }
+
+// Frames returns a channel that receives the time of each flush of the window to the screen.
+// The window does not block sending to it, so the frames nobody receives get dropped. See
+// anim.Framer.
+func (w *Win) Frames() <-chan time.Time { return w.damage.Frames() }
+
+var buttons = map[byte]win.Button{
+	1: win.ButtonLeft,
+	2: win.ButtonMiddle,
+	3: win.ButtonRight,
+}
+
+// scrolls are the amounts scrolled by the buttons the X server uses for the mouse wheel
+var scrolls = map[byte]image.Point{
+	4: {0, 1},
+	5: {0, -1},
+	6: {1, 0},
+	7: {-1, 0},
+}
+
+func (w *Win) eventLoop() {
+	defer close(w.eventsIn)
+
+	size := w.img.Bounds().Size()
+	pressed := make(map[byte]bool) // keycodes
+
+	for {
+		b, err := w.c.nextEvent()
+		if err != nil {
+			// the connection got closed, either by closing the window or by the server
+			select {
+			case <-w.finish:
+			default:
+				w.eventsIn <- win.WiClose{}
+			}
+			return
+		}
+
+		le := binary.LittleEndian
+		pt := func() image.Point {
+			return image.Pt(int(int16(le.Uint16(b[24:]))), int(int16(le.Uint16(b[26:]))))
+		}
+
+		switch b[0] & 0x7f {
+		case evKeyPress:
+			keycode, state := b[1], le.Uint16(b[28:])
+			if k, ok := w.keymap.key(keycode); ok {
+				if pressed[keycode] {
+					w.eventsIn <- win.KbRepeat{Key: k}
+				} else {
+					w.eventsIn <- win.KbDown{Key: k}
+				}
+			}
+			pressed[keycode] = true
+			if r, ok := w.keymap.rune(keycode, state); ok {
+				w.eventsIn <- win.KbType{Rune: r}
+			}
+
+		case evKeyRelease:
+			keycode := b[1]
+			// the X server repeats a held key by sending a release and a press at the same
+			// time, it's not a release if the press is waiting right behind it
+			if next := w.c.peekEvent(); next != nil && next[0]&0x7f == evKeyPress &&
+				next[1] == keycode && le.Uint32(next[4:]) == le.Uint32(b[4:]) {
+				continue
+			}
+			delete(pressed, keycode)
+			if k, ok := w.keymap.key(keycode); ok {
+				w.eventsIn <- win.KbUp{Key: k}
+			}
+
+		case evButtonPress:
+			if btn, ok := buttons[b[1]]; ok {
+				w.eventsIn <- win.MoDown{Point: pt(), Button: btn}
+			} else if scroll, ok := scrolls[b[1]]; ok {
+				w.eventsIn <- win.MoScroll{Point: scroll}
+			}
+
+		case evButtonRelease:
+			if btn, ok := buttons[b[1]]; ok {
+				w.eventsIn <- win.MoUp{Point: pt(), Button: btn}
+			}
+
+		case evMotionNotify:
+			w.eventsIn <- win.MoMove{Point: pt()}
+
+		case evExpose:
+			x, y := int(le.Uint16(b[8:])), int(le.Uint16(b[10:]))
+			width, height := int(le.Uint16(b[12:])), int(le.Uint16(b[14:]))
+			select {
+			case w.expose <- image.Rect(x, y, x+width, y+height):
+			case <-w.finish:
+			}
+
+		case evConfigureNotify:
+			newSize := image.Pt(int(le.Uint16(b[20:])), int(le.Uint16(b[22:])))
+			if newSize == size {
+				continue
+			}
+			size = newSize
+			r := image.Rectangle{Max: size}
+			select {
+			case w.newSize <- r:
+			case <-w.finish:
+			}
+			w.eventsIn <- gui.Resize{Rectangle: r}
+
+		case evClientMessage:
+			if le.Uint32(b[8:]) == w.wmProtocols && le.Uint32(b[12:]) == w.wmDeleteWindow {
+				w.eventsIn <- win.WiClose{}
+			}
+		}
+	}
+}
+
+func (w *Win) drawLoop() {
+	for {
+		select {
+		case r := <-w.newSize:
+			img := image.NewRGBA(r)
+			draw.Draw(img, w.img.Bounds(), w.img, w.img.Bounds().Min, draw.Src)
+			w.img = img
+			w.damage.Add(r)
+
+		case r := <-w.expose:
+			w.damage.Add(r)
+
+		case d, ok := <-w.draw:
+			if !ok {
+				close(w.finish)
+				w.c.send(
+					newRequest(opFreeGC, 0).u32(w.gc),
+					newRequest(opDestroyWindow, 0).u32(w.window),
+				)
+				w.c.close()
+				return
+			}
+			w.damage.Add(w.recovery.Draw(w, d, w.img))
+
+		case <-w.damage.Ready():
+			if r := w.damage.Take(w.img.Bounds()); !r.Empty() {
+				w.flush(r)
+				w.damage.Flushed()
+			}
+		}
+	}
+}
+
+// flush uploads the rectangle of the image to the window using PutImage requests, splitting it
+// into strips of rows to respect the maximum request length of the server.
+func (w *Win) flush(r image.Rectangle) {
+	r = r.Intersect(w.img.Bounds())
+	if r.Empty() {
+		return
+	}
+	const headerLen = 24
+	rowLen := 4 * r.Dx()
+	rows := (w.c.setup.maxRequestLen - headerLen) / rowLen
+	if rows < 1 {
+		rows = 1
+	}
+	msb := w.c.setup.imageMSBFirst
+	for y := r.Min.Y; y < r.Max.Y; y += rows {
+		strip := image.Rect(r.Min.X, y, r.Max.X, y+rows).Intersect(r)
+		req := newRequest(opPutImage, 2) // ZPixmap
+		req = req.u32(w.window).u32(w.gc)
+		req = req.u16(uint16(strip.Dx())).u16(uint16(strip.Dy()))
+		req = req.u16(uint16(strip.Min.X)).u16(uint16(strip.Min.Y))
+		req = req.u8(0).u8(w.c.setup.rootDepth).u16(0)
+		for sy := strip.Min.Y; sy < strip.Max.Y; sy++ {
+			i := w.img.PixOffset(strip.Min.X, sy)
+			row := w.img.Pix[i : i+rowLen]
+			for x := 0; x < rowLen; x += 4 {
+				if msb {
+					req = append(req, 0, row[x], row[x+1], row[x+2])
+				} else {
+					req = append(req, row[x+2], row[x+1], row[x], 0)
+				}
+			}
+		}
+		if w.c.send(req) != nil {
+			return
+		}
+	}
+}
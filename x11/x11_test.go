@@ -0,0 +1,339 @@
+package x11
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faiface/gui"
+)
+
+// xServer is the server side of an X11 connection, speaking just enough of the protocol for the
+// window.
+type xServer struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+const (
+	testResourceBase = 0x400000
+	testRoot         = 0x100
+	testVisual       = 0x21
+	testMaxRequest   = 24 + 2*8*4 // two rows of an 8 pixels wide image per PutImage
+
+	atomWMProtocols    = 100
+	atomWMDeleteWindow = 101
+)
+
+func (s *xServer) read(n int) []byte {
+	s.t.Helper()
+	b := make([]byte, n)
+	s.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		s.t.Fatal(err)
+	}
+	return b
+}
+
+func (s *xServer) write(b ...byte) {
+	s.t.Helper()
+	if _, err := s.conn.Write(b); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// request reads a request and checks its opcode.
+func (s *xServer) request(opcode byte) []byte {
+	s.t.Helper()
+	head := s.read(4)
+	if head[0] != opcode {
+		s.t.Fatalf("got request %d, want %d", head[0], opcode)
+	}
+	n := 4 * int(binary.LittleEndian.Uint16(head[2:]))
+	if n < 4 {
+		s.t.Fatalf("request %d has length %d", opcode, n)
+	}
+	return append(head, s.read(n-4)...)
+}
+
+// packet encodes a 32 bytes long event or reply, the fields are put at the offsets.
+func packet(code, detail byte, fields map[int]uint32) []byte {
+	b := make([]byte, 32)
+	b[0], b[1] = code, detail
+	for off, v := range fields {
+		binary.LittleEndian.PutUint32(b[off:], v)
+	}
+	return b
+}
+
+// pointerEvent encodes a key, button or motion event at the point of the window.
+func pointerEvent(code, detail byte, at uint32, x, y, state uint16) []byte {
+	return packet(code, detail, map[int]uint32{
+		4:  at,
+		24: uint32(x) | uint32(y)<<16,
+		28: uint32(state),
+	})
+}
+
+// setupReply encodes the reply to the connection setup, with one screen of a 24 bit TrueColor
+// visual and keycodes 8 to 10.
+func setupReply() []byte {
+	le := binary.LittleEndian
+	vendor := "fake"
+	body := make([]byte, 32)
+	le.PutUint32(body[4:], testResourceBase)
+	le.PutUint32(body[8:], 0x1fffff)
+	le.PutUint16(body[16:], uint16(len(vendor)))
+	le.PutUint16(body[18:], testMaxRequest/4)
+	body[20], body[21] = 1, 1 // screens, formats
+	body[26], body[27] = 8, 10
+	body = append(body, vendor...)
+	body = append(body, make([]byte, pad4(len(vendor)))...)
+	body = append(body, 24, 32, 32, 0, 0, 0, 0, 0) // format
+
+	screen := make([]byte, 40)
+	le.PutUint32(screen[0:], testRoot)
+	le.PutUint32(screen[32:], testVisual)
+	screen[38], screen[39] = 24, 1 // root depth, depths
+	body = append(body, screen...)
+	body = append(body, 24, 0, 1, 0, 0, 0, 0, 0) // depth with one visual
+	visual := make([]byte, 24)
+	le.PutUint32(visual[0:], testVisual)
+	visual[4], visual[5] = 4, 8 // TrueColor, bits per RGB
+	le.PutUint32(visual[8:], 0xff0000)
+	le.PutUint32(visual[12:], 0xff00)
+	le.PutUint32(visual[16:], 0xff)
+	body = append(body, visual...)
+
+	head := []byte{1, 0, 11, 0, 0, 0, 0, 0}
+	le.PutUint16(head[6:], uint16(len(body)/4))
+	return append(head, body...)
+}
+
+// fakeServer listens on a Unix socket and returns the display name to connect to it.
+func fakeServer(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	t.Setenv("XAUTHORITY", filepath.Join(t.TempDir(), "none"))
+	// a display starting with a slash is the path of the socket, like the ones of launchd
+	display := filepath.Join(t.TempDir(), "X:0")
+	l, err := net.Listen("unix", display)
+	if err != nil {
+		t.Skip(err)
+	}
+	l.(*net.UnixListener).SetDeadline(time.Now().Add(3 * time.Second))
+	t.Cleanup(func() { l.Close() })
+	return l, display
+}
+
+// accept accepts the window's connection and checks its setup request.
+func accept(t *testing.T, l net.Listener) *xServer {
+	t.Helper()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &xServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+	if req := s.read(12); !bytes.Equal(req, []byte{'l', 0, 11, 0, 0, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Fatalf("got setup request %v, want little-endian protocol 11 without authorization", req)
+	}
+	return s
+}
+
+// open creates a window connected to the fake server and answers its requests until it maps
+// the window.
+func open(t *testing.T, opts ...Option) (*Win, *xServer) {
+	t.Helper()
+	l, display := fakeServer(t)
+	type result struct {
+		w   *Win
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		w, err := New(append(opts, Display(display))...)
+		done <- result{w, err}
+	}()
+
+	s := accept(t, l)
+	s.write(setupReply()...)
+	for i, name := range []string{"WM_PROTOCOLS", "WM_DELETE_WINDOW", "_NET_WM_NAME", "UTF8_STRING"} {
+		req := s.request(opInternAtom)
+		n := int(binary.LittleEndian.Uint16(req[4:]))
+		if got := string(req[8 : 8+n]); got != name {
+			t.Fatalf("interning %q, want %q", got, name)
+		}
+		if i == 0 {
+			// an event coming before a reply waits for the event loop
+			s.write(pointerEvent(evMotionNotify, 0, 0, 1, 2, 0)...)
+		}
+		s.write(packet(1, 0, map[int]uint32{8: atomWMProtocols + uint32(i)})...)
+	}
+
+	req := s.request(opGetKeyboardMapping)
+	if req[4] != 8 || req[5] != 3 {
+		t.Fatalf("got the mapping of %d keycodes from %d, want 3 from 8", req[5], req[4])
+	}
+	s.write(packet(1, 2, map[int]uint32{4: 6})...)
+	for _, sym := range []uint32{'a', 'A', 0xffe1, 0, 0xff09, 0} { // a, Shift_L, Tab
+		s.write(byte(sym), byte(sym>>8), byte(sym>>16), byte(sym>>24))
+	}
+
+	req = s.request(opCreateWindow)
+	le := binary.LittleEndian
+	if id, parent := le.Uint32(req[4:]), le.Uint32(req[8:]); id != testResourceBase|1 || parent != testRoot {
+		t.Errorf("created window %#x in %#x, want %#x in the root %#x", id, parent, testResourceBase|1, testRoot)
+	}
+	if size := image.Pt(int(le.Uint16(req[16:])), int(le.Uint16(req[18:]))); size != image.Pt(8, 4) {
+		t.Errorf("created a window of size %v, want (8,4)", size)
+	}
+	for _, opcode := range []byte{opChangeProperty, opChangeProperty, opChangeProperty, opChangeProperty, opCreateGC, opMapWindow} {
+		s.request(opcode)
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	return res.w, s
+}
+
+// expect receives the events from the Env and compares them with want, "closed" standing for the
+// Events() channel getting closed.
+func expect(t *testing.T, env gui.Env, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		got := "closed"
+		select {
+		case e, ok := <-env.Events():
+			if ok {
+				got = e.String()
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no event within 3s, want %s", w)
+		}
+		if got != w {
+			t.Fatalf("got %s, want %s", got, w)
+		}
+	}
+}
+
+// putImage reads a PutImage request and returns its rectangle and its pixels.
+func (s *xServer) putImage() (image.Rectangle, []byte) {
+	s.t.Helper()
+	req := s.request(opPutImage)
+	le := binary.LittleEndian
+	if req[1] != 2 || le.Uint32(req[4:]) != testResourceBase|1 || req[21] != 24 {
+		s.t.Errorf("got PutImage of format %d to %#x in depth %d, want ZPixmap to the window in depth 24",
+			req[1], le.Uint32(req[4:]), req[21])
+	}
+	x, y := int(le.Uint16(req[16:])), int(le.Uint16(req[18:]))
+	w, h := int(le.Uint16(req[12:])), int(le.Uint16(req[14:]))
+	return image.Rect(x, y, x+w, y+h), req[24:]
+}
+
+func TestWindow(t *testing.T) {
+	w, s := open(t, Size(8, 4))
+	expect(t, w, "resize/0/0/8/4", "mo/move/1/2")
+
+	// the image gets uploaded in strips respecting the maximum request length
+	w.Draw() <- func(drw draw.Image) image.Rectangle {
+		r := image.Rect(1, 0, 8, 3)
+		draw.Draw(drw, r, &image.Uniform{color.RGBA{1, 2, 3, 255}}, image.ZP, draw.Src)
+		return r
+	}
+	for _, want := range []image.Rectangle{image.Rect(1, 0, 8, 2), image.Rect(1, 2, 8, 3)} {
+		r, pixels := s.putImage()
+		if r != want {
+			t.Fatalf("got PutImage of %v, want %v", r, want)
+		}
+		if !bytes.Equal(pixels, bytes.Repeat([]byte{3, 2, 1, 0}, r.Dx()*r.Dy())) {
+			t.Errorf("got pixels starting with %v, want blue, green, red and 0", pixels[:8])
+		}
+	}
+	s.write(packet(evExpose, 0, map[int]uint32{8: 0 | 3<<16, 12: 2 | 1<<16})...)
+	if r, pixels := s.putImage(); r != image.Rect(0, 3, 2, 4) || !bytes.Equal(pixels, make([]byte, 8)) {
+		t.Errorf("got PutImage of %v with %v after an Expose, want the black (0,3)-(2,4)", r, pixels)
+	}
+
+	s.write(pointerEvent(evKeyPress, 8, 1, 0, 0, 0)...)
+	s.write(pointerEvent(evKeyPress, 9, 2, 0, 0, 0)...)
+	s.write(pointerEvent(evKeyPress, 8, 3, 0, 0, shiftMask)...)
+	s.write(pointerEvent(evKeyRelease, 9, 4, 0, 0, shiftMask)...)
+	// a held key repeats by a release and a press at the same time
+	s.write(pointerEvent(evKeyPress, 10, 5, 0, 0, 0)...)
+	s.write(append(pointerEvent(evKeyRelease, 10, 6, 0, 0, 0), pointerEvent(evKeyPress, 10, 6, 0, 0, 0)...)...)
+	s.write(pointerEvent(evKeyRelease, 10, 7, 0, 0, 0)...)
+	expect(t, w, "kb/type/97", "kb/down/shift", "kb/type/65", "kb/up/shift",
+		"kb/down/tab", "kb/repeat/tab", "kb/up/tab")
+
+	s.write(pointerEvent(evButtonPress, 1, 8, 3, 2, 0)...)
+	s.write(pointerEvent(evMotionNotify, 0, 9, 5, 1, 1<<8)...)
+	s.write(pointerEvent(evButtonRelease, 1, 10, 5, 1, 1<<8)...)
+	s.write(pointerEvent(evButtonPress, 5, 11, 5, 1, 0)...)
+	s.write(pointerEvent(evButtonPress, 3, 12, 0xffff, 0xfffe, 0)...) // outside of the window
+	expect(t, w, "mo/down/3/2/left", "mo/move/5/1", "mo/up/5/1/left", "mo/scroll/0/-1",
+		"mo/down/-1/-2/right")
+
+	// the window keeps its image when it grows
+	s.write(packet(evConfigureNotify, 0, map[int]uint32{20: 10 | 6<<16})...)
+	expect(t, w, "resize/0/0/10/6")
+	for y := 0; y < 6; y++ {
+		// the rows are 10 pixels wide now, one row per strip
+		r, pixels := s.putImage()
+		if r != image.Rect(0, y, 10, y+1) {
+			t.Fatalf("got PutImage of %v after growing, want row %d", r, y)
+		}
+		if want := []byte{0, 0, 0, 0, 3, 2, 1, 0}; y == 0 && !bytes.Equal(pixels[:8], want) {
+			t.Errorf("got the row starting with %v, want %v", pixels[:8], want)
+		}
+	}
+
+	s.write(packet(evClientMessage, 32, map[int]uint32{8: atomWMProtocols, 12: atomWMDeleteWindow})...)
+	expect(t, w, "wi/close")
+
+	close(w.Draw())
+	s.request(opFreeGC)
+	s.request(opDestroyWindow)
+	expect(t, w, "closed")
+}
+
+func TestServerCloses(t *testing.T) {
+	w, s := open(t, Size(8, 4))
+	expect(t, w, "resize/0/0/8/4", "mo/move/1/2")
+	s.conn.Close()
+	expect(t, w, "wi/close", "closed")
+	close(w.Draw())
+}
+
+func TestConnectionRefused(t *testing.T) {
+	l, display := fakeServer(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := New(Display(display))
+		done <- err
+	}()
+	s := accept(t, l)
+	reason := "no way"
+	reply := []byte{0, byte(len(reason)), 11, 0, 0, 0, 2, 0}
+	s.write(append(reply, reason+strings.Repeat(" ", pad4(len(reason)))...)...)
+
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "x11: connection refused: no way" {
+			t.Errorf("got error %v, want the refusal", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("New did not return within 3s")
+	}
+}